@@ -4,28 +4,386 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 
 	"golang.org/x/tools/imports"
 )
 
 var (
-	// Struct field generated from an element attribute
-	attr = `{{ define "Attr" }}{{ printf "  %s " (lintTitle .Name) }}{{ printf "%s ` + "`xml:\\\"%s,attr\\\"`" + `" (lint .Type) .Name }}
+	// Struct field generated from an element attribute; a pointer in
+	// optional mode, omitted when nil, so that absent ones are told apart
+	attr = `{{ define "Attr" }}{{ printf "  %s " (lintTitle .Name) }}{{ if optionalAttr . }}{{ printf "*%s ` + "`xml:\\\"%s,attr,omitempty\\\"`" + `" (typeName .Type) (xmlName .Namespace .Qualified .Name) }}{{ else }}{{ printf "%s ` + "`xml:\\\"%s,attr\\\"`" + `" (typeName .Type) (xmlName .Namespace .Qualified .Name) }}{{ end }}
 {{ end }}`
 
-	// Struct field generated from an element child element
-	child = `{{ define "Child" }}{{ printf "  %s " (lintTitle .Name) }}{{ if .List }}[]{{ end }}{{ printf "%s ` + "`xml:\\\"%s\\\"`" + `" (typeName (fieldType .)) .Name }}
-{{ end }}`
+	// Struct field generated from an element child element; a reference
+	// back to an enclosing type must be a pointer, as must an optional
+	// element in optional mode
+	child = `{{ define "Child" }}{{ if .Choice }}{{ template "Choice" . }}{{ else if optionalElem . }}{{ printf "  %s *%s ` + "`xml:\\\"%s,omitempty\\\"`" + `" (lintTitle .Name) (typeName .Type) (xmlName .Namespace .Qualified .Name) }}
+{{ else }}{{ printf "  %s " (lintTitle .Name) }}{{ if .List }}[]{{ else if .Recursive }}*{{ end }}{{ printf "%s ` + "`xml:\\\"%s\\\"`" + `" (typeName .Type) (xmlName .Namespace .Qualified .Name) }}
+{{ end }}{{ end }}`
+
+	// Struct fields generated from a choice; either a single field holding
+	// the chosen alternative, or an optional field per alternative. A
+	// sequence alternative is an embedded struct of optional fields
+	choice = `{{ define "Choice" }}{{ if choiceIface }}{{ printf "  %s " (lintTitle .Name) }}{{ if choiceList . }}[]{{ else }}*{{ end }}{{ printf "%s ` + "`xml:\\\",any\\\"`" + `" (typeName .Type) }}
+{{ else }}{{ $list := .List }}{{ range $a := .Children }}{{ if $a.Sequence }}{{ printf "  %s\n" (typeName $a.Type) }}{{ else }}{{ printf "  %s " (lintTitle $a.Name) }}{{ if or $list $a.List }}[]{{ else }}*{{ end }}{{ printf "%s ` + "`xml:\\\"%s\\\"`" + `" (typeName $a.Type) (xmlName $a.Namespace $a.Qualified $a.Name) }}
+{{ end }}{{ end }}{{ end }}{{ end }}`
 
 	// Struct field generated from the character data of an element
-	cdata = `{{ define "Cdata" }}{{ printf "%s %s ` + "`xml:\\\",chardata\\\"`" + `" (lintTitle .Name) (lint .Type) }}
+	cdata = `{{ define "Cdata" }}{{ printf "  Value %s ` + "`xml:\\\",chardata\\\"`" + `" (typeName .CdataType) }}
 {{ end }}`
 
-	// Struct generated from a non-trivial element (with children and/or attributes)
-	elem = `{{ printf "// %s is generated from an XSD element\ntype %s struct {\n" (typeName .Name) (typeName .Name) }}{{ range $a := .Attribs }}{{ template "Attr" $a }}{{ end }}{{ range $c := .Children }}{{ template "Child" $c }}{{ end }} {{ if .Cdata }}{{ template "Cdata" . }}{{ end }} }
-`
+	// Name of a root element, as the XMLName field of its struct
+	xmlname = `{{ define "XMLName" }}{{ printf "  XMLName xml.Name ` + "`xml:\\\"%s\\\"`" + `" (xmlName .Namespace .Qualified .Name) }}
+{{ end }}`
+
+	// Struct generated from a non-trivial element (with children and/or
+	// attributes). A root element of an abstract type encodes and decodes
+	// by the holder it embeds, under its own name.
+	elem = `{{ printf "// %s is generated from an XSD %s\ntype %s struct {\n" (typeName .Type) (or (and .Named "complex type") "element") (typeName .Type) }}{{ if isRoot . }}{{ template "XMLName" . }}{{ end }}{{ if .Embed }}  {{ if .Embed.Recursive }}*{{ end }}{{ printf "%s\n" (typeName .Embed.Type) }}{{ end }}{{ range $a := .Attribs }}{{ template "Attr" $a }}{{ end }}{{ range $c := .Children }}{{ template "Child" $c }}{{ end }} {{ if .Cdata }}{{ template "Cdata" . }}{{ end }} }
+{{ if and .Embed .Embed.Abstract }}
+func (v {{ typeName .Type }}) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{ {{- if .Qualified }}Space: "{{ .Namespace }}", {{ end }}Local: "{{ .Name }}"}
+	return v.{{ typeName .Embed.Type }}.MarshalXML(e, start)
+}
+
+func (v *{{ typeName .Type }}) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Local != "{{ .Name }}" {
+		return fmt.Errorf("expected element type <{{ .Name }}> but have <%s>", start.Name.Local)
+	}
+{{ if and .Qualified .Namespace }}	if start.Name.Space != "{{ .Namespace }}" {
+		return fmt.Errorf("expected element <{{ .Name }}> in name space {{ .Namespace }} but have %q", start.Name.Space)
+	}
+{{ end }}	v.XMLName = start.Name
+	return v.{{ typeName .Embed.Type }}.UnmarshalXML(d, start)
+}
+{{ end }}{{ if unqualifiedWithin . }}{{ template "EncodeUnqualified" . }}{{ end }}{{ if decodesChoices . }}{{ template "DecodeChoices" . }}{{ end }}{{ if validate }}{{ template "ValidateStruct" . }}{{ end }}`
+
+	// Struct generated from a sequence that is an alternative of a choice.
+	// Its fields are optional when it is embedded in the struct holding the
+	// choice, rather than held as an alternative of a sealed interface
+	sequence = `{{ define "Sequence" }}{{ $t := typeName .Type }}{{ $list := .List }}
+// {{ $t }} is generated from an XSD sequence within a choice
+type {{ $t }} struct {
+{{ range $c := .Children }}{{ if choiceIface }}{{ template "Child" $c }}{{ else }}{{ printf "  %s " (lintTitle $c.Name) }}{{ if or $list $c.List }}[]{{ else }}*{{ end }}{{ printf "%s ` + "`xml:\\\"%s\\\"`" + `" (typeName $c.Type) (xmlName $c.Namespace $c.Qualified $c.Name) }}
+{{ end }}{{ end }}}
+{{ if validate }}{{ template "ValidateStruct" . }}{{ end }}{{ end }}`
+
+	// UnmarshalXML generated for a struct holding choices as sealed
+	// interfaces. The alternatives of the choices are decoded by their
+	// element names, and any other child elements by the struct tags. The
+	// methods of embedded structs are hidden from the latter.
+	decodeChoices = `{{ define "DecodeChoices" }}
+func (v *{{ typeName .Type }}) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Fields {{ typeName .Type }}
+	var x struct {
+		*Fields
+{{ if .Embed }}		UnmarshalXML struct{} ` + "`xml:\"-\"`" + `
+{{ end }}	}
+	x.Fields = (*Fields)(v)
+{{ choiceDecoding . }}}
+{{ end }}`
+
+	// MarshalXML generated for a struct holding unqualified elements of a
+	// namespace. Its name is bound to its namespace by a prefix instead of
+	// the default namespace, which encoding/xml would declare and the
+	// elements within inherit. The methods of embedded structs are hidden.
+	encodeUnqualified = `{{ define "EncodeUnqualified" }}
+// MarshalXML encodes v under a prefixed name, leaving its unqualified elements in no namespace
+func (v {{ typeName .Type }}) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type Fields {{ typeName .Type }}
+	x := struct {
+		*Fields
+{{ if .Embed }}		MarshalXML struct{} ` + "`xml:\"-\"`" + `
+{{ end }}	}{Fields: (*Fields)(&v)}
+{{ if isRoot . }}	start.Name = xml.Name{ {{- if .Qualified }}Space: "{{ .Namespace }}", {{ end }}Local: "{{ .Name }}"}
+{{ end }}	return e.EncodeElement(x, xsdtype.PrefixName(start))
+}
+{{ end }}`
+
+	// Sealed interface generated from a choice, with a type per alternative
+	// and a holder that decodes and encodes the chosen alternative by its
+	// element name
+	choiceType = `{{ define "ChoiceType" }}{{ $t := typeName .Type }}{{ $m := printf "is%s" (lintTitle $t) }}
+// {{ $t }} holds one of the alternatives of an XSD choice
+type {{ $t }} struct {
+	Value {{ $t }}Value
+}
+
+// {{ $t }}Value is implemented by the alternatives of {{ $t }}
+type {{ $t }}Value interface {
+	{{ $m }}()
+}
+{{ range $a := .Children }}{{ if $a.Sequence }}
+func ({{ typeName $a.Type }}) {{ $m }}() {}
+{{ else }}{{ $at := typeName (printf "%s%s" $.Type (lintTitle $a.Name)) }}
+type {{ $at }} struct {
+{{ if simpleElement $a }}	Value {{ typeName $a.Type }} ` + "`xml:\",chardata\"`" + `
+{{ else }}	{{ typeName $a.Type }}
+{{ end }}}
+
+func ({{ $at }}) {{ $m }}() {}
+{{ end }}{{ end }}
+func (c *{{ $t }}) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+{{ range $a := .Children }}{{ if not $a.Sequence }}	case "{{ $a.Name }}":
+		var v {{ typeName (printf "%s%s" $.Type (lintTitle $a.Name)) }}
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		c.Value = v
+		return nil
+{{ end }}{{ end }}	}
+	return fmt.Errorf("unexpected element %s, not an alternative of {{ $t }}", start.Name.Local)
+}
+
+// MarshalXML encodes the chosen alternative; a sequence as its elements
+func (c {{ $t }}) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch v := c.Value.(type) {
+{{ range $a := .Children }}{{ if $a.Sequence }}	case {{ typeName $a.Type }}:
+{{ range $c := $a.Children }}		if err := e.EncodeElement(v.{{ lintTitle $c.Name }}, xml.StartElement{Name: xml.Name{ {{- if $c.Qualified }}Space: "{{ $c.Namespace }}", {{ end }}Local: "{{ $c.Name }}"}}); err != nil {
+			return err
+		}
+{{ end }}		return nil
+{{ else }}	case {{ typeName (printf "%s%s" $.Type (lintTitle $a.Name)) }}:
+		start.Name = xml.Name{ {{- if $a.Qualified }}Space: "{{ $a.Namespace }}", {{ end }}Local: "{{ $a.Name }}"}
+		return e.EncodeElement(v, start)
+{{ end }}{{ end }}	}
+	return nil
+}
+{{ if validate }}{{ template "ValidateChoice" . }}{{ end }}{{ end }}`
+
+	// Holder generated for the elements of an abstract type, with a sealed
+	// interface implemented by the types derived from it, and a registry of
+	// those by qualified name. Decoding a value looks its type up by the
+	// xsi:type attribute of the element, which encoding writes, declaring
+	// the namespace of the type.
+	abstractType = `{{ define "AbstractType" }}{{ $t := typeName .Type }}{{ $m := printf "is%s" (lintTitle $t) }}
+// {{ $t }} holds a value of any of the types derived from an abstract XSD
+// type
+type {{ $t }} struct {
+	Value {{ $t }}Value
+}
+
+// {{ $t }}Value is implemented by the types derived from the abstract XSD
+// type of {{ $t }}
+type {{ $t }}Value interface {
+	{{ $m }}()
+}
+{{ range $d := .Derived }}
+func ({{ typeName $d.Type }}) {{ $m }}() {}
+{{ end }}
+// {{ $t }}Types holds the types derived from the abstract XSD type of {{ $t }},
+// by their qualified names as given by xsi:type
+var {{ $t }}Types = map[xml.Name]func() {{ $t }}Value{
+{{ range $d := .Derived }}	{ {{- if $d.Namespace }}Space: "{{ $d.Namespace }}", {{ end }}Local: "{{ $d.Name }}"}: func() {{ $t }}Value { return new({{ typeName $d.Type }}) },
+{{ end }}}
+
+func (h *{{ $t }}) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, a := range start.Attr {
+		if a.Name.Space != "http://www.w3.org/2001/XMLSchema-instance" || a.Name.Local != "type" {
+			continue
+		}
+		names := make([]xml.Name, 0, len({{ $t }}Types))
+		for n := range {{ $t }}Types {
+			names = append(names, n)
+		}
+		typ, ok := xsdtype.TypeName(start, a.Value, names)
+		if !ok {
+			return fmt.Errorf("unknown xsi:type %q of element %s", a.Value, start.Name.Local)
+		}
+		v := {{ $t }}Types[typ]()
+		if err := d.DecodeElement(v, &start); err != nil {
+			return err
+		}
+		h.Value = v
+		return nil
+	}
+	return fmt.Errorf("missing xsi:type of element %s, of an abstract type", start.Name.Local)
+}
+
+// MarshalXML encodes the value under the qualified name of its type, given
+// by xsi:type
+func (h {{ $t }}) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var typ, ns string
+	switch h.Value.(type) {
+{{ range $d := .Derived }}	case {{ typeName $d.Type }}, *{{ typeName $d.Type }}:
+		typ, ns = "{{ $d.Name }}", "{{ $d.Namespace }}"
+{{ end }}	case nil:
+		return fmt.Errorf("no value of element %s, of an abstract type", start.Name.Local)
+	default:
+		return fmt.Errorf("value of element %s of type %T, not derived from its abstract type", start.Name.Local, h.Value)
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"})
+	if ns != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:tns"}, Value: ns})
+		typ = "tns:" + typ
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: typ})
+	return e.EncodeElement(h.Value, start)
+}
+{{ if validate }}{{ template "ValidateAbstract" . }}{{ end }}{{ end }}`
+
+	// Struct generated for the elements of xs:anyType, holding their
+	// attributes and content as they are. Encoding leaves the default
+	// namespace to the encoder, which declares that of the element, but
+	// writes prefixed declarations back as such, for the content to use,
+	// along with the attributes in their namespaces, so that the encoder
+	// declares none of them again.
+	anyType = `{{ define "AnyType" }}{{ $t := typeName .Type }}
+// {{ $t }} holds the attributes and content of an element of xs:anyType
+type {{ $t }} struct {
+	Attrs   []xml.Attr ` + "`xml:\",any,attr\"`" + `
+	Content string     ` + "`xml:\",innerxml\"`" + `
+}
+
+// MarshalXML encodes the attributes and content as they were decoded
+func (v {{ $t }}) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	prefixes := make(map[string]string)
+	for _, a := range v.Attrs {
+		if a.Name.Space == "xmlns" {
+			prefixes[a.Value] = a.Name.Local
+		}
+	}
+	for _, a := range v.Attrs {
+		if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		if a.Name.Space == "xmlns" {
+			a.Name = xml.Name{Local: "xmlns:" + a.Name.Local}
+		} else if p, ok := prefixes[a.Name.Space]; ok {
+			a.Name = xml.Name{Local: p + ":" + a.Name.Local}
+		}
+		start.Attr = append(start.Attr, a)
+	}
+	return e.EncodeElement(struct {
+		Content string ` + "`xml:\",innerxml\"`" + `
+	}{v.Content}, start)
+}
+{{ if validate }}{{ template "ValidateStruct" . }}{{ end }}{{ end }}`
+
+	// Type generated from a simple type. An enumeration gets a constant per
+	// value, or a variable for decimals and integers of arbitrary size, which
+	// are compared by value, and in strict mode, an UnmarshalText method
+	// rejecting values not enumerated, which covers elements, attributes and
+	// character data. A flat simple type only gets a function validating its
+	// values.
+	simpleType = `{{ define "SimpleType" }}{{ if .Flat }}{{ template "ValidateFlat" . }}{{ else if .List }}{{ template "ListType" . }}{{ else if .Union }}{{ template "UnionType" . }}{{ else }}{{ $t := typeName .Name }}
+// {{ $t }} is generated from an XSD simple type
+type {{ $t }} {{ .Base }}
+{{ if runtimeType .Base }}
+func (v {{ $t }}) MarshalText() ([]byte, error) {
+	return {{ .Base }}(v).MarshalText()
+}
+{{ if not (and strict .Values) }}
+func (v *{{ $t }}) UnmarshalText(b []byte) error {
+	return (*{{ .Base }})(v).UnmarshalText(b)
+}
+{{ end }}{{ end }}{{ if .Values }}{{ $big := bigNumber .Base }}
+// Values of {{ $t }}
+{{ if $big }}var{{ else }}const{{ end }} (
+{{ range $c := enumConsts . }}	{{ $c.Name }} {{ $t }} = {{ if $big }}{{ $t }}({{ $c.Value }}){{ else }}{{ $c.Value }}{{ end }}
+{{ end }})
+
+func (v {{ $t }}) String() string {
+	return {{ if eq .Base "string" }}string(v){{ else }}fmt.Sprint({{ .Base }}(v)){{ end }}
+}
+
+// IsValid reports whether v is one of the enumerated values of {{ $t }}
+func (v {{ $t }}) IsValid() bool {
+{{ if $big }}	for _, x := range []{{ $t }}{ {{- range $i, $c := enumConsts . }}{{ if $i }}, {{ end }}{{ $c.Name }}{{ end }}} {
+		if {{ .Base }}(v).Cmp({{ .Base }}(x)) == 0 {
+			return true
+		}
+	}
+{{ else }}	switch v {
+	case {{ range $i, $c := enumConsts . }}{{ if $i }}, {{ end }}{{ $c.Name }}{{ end }}:
+		return true
+	}
+{{ end }}	return false
+}
+{{ if strict }}
+// UnmarshalText rejects values not enumerated, whether of an element, an
+// attribute or character data{{ if not optional }}, other than the zero value,
+// which absent optional fields are encoded as{{ end }}
+func (v *{{ $t }}) UnmarshalText(text []byte) error {
+	{{ parseText .Base "string(text)" "return err" }}
+	x := {{ $t }}(b)
+{{ if optional }}	if !x.IsValid() {
+{{ else if $big }}	if !x.IsValid() && {{ .Base }}(x).Sign() != 0 {
+{{ else }}	var zero {{ $t }}
+	if !x.IsValid() && x != zero {
+{{ end }}		return fmt.Errorf("invalid {{ $t }} value: %v", b)
+	}
+	*v = x
+	return nil
+}
+{{ end }}{{ end }}{{ if validated . }}{{ template "ValidateSimpleType" . }}{{ end }}{{ end }}{{ end }}`
+
+	// Type generated from a list type, a slice of its items, which are
+	// separated by whitespace when encoded. Items of a union type, or of an
+	// enumeration in strict mode, are decoded by their own UnmarshalText.
+	listType = `{{ define "ListType" }}{{ $t := typeName .Name }}
+// {{ $t }} is generated from an XSD list type
+type {{ $t }} []{{ typeName .ItemType }}
+
+func (v {{ $t }}) MarshalText() ([]byte, error) {
+	items := make([]string, len(v))
+	for i, x := range v {
+		{{ formatItem .Base }}
+	}
+	return []byte(strings.Join(items, " ")), nil
+}
+
+func (v *{{ $t }}) UnmarshalText(text []byte) error {
+	var l {{ $t }}
+	for _, f := range strings.Fields(string(text)) {
+{{ if itemUnmarshaler . }}		var x {{ typeName .ItemType }}
+		if err := x.UnmarshalText([]byte(f)); err != nil {
+			return err
+		}
+		l = append(l, x)
+{{ else }}		{{ parseText .Base "f" "return err" }}
+		l = append(l, {{ typeName .ItemType }}(b))
+{{ end }}	}
+	*v = l
+	return nil
+}
+{{ if validated . }}{{ template "ValidateSimpleType" . }}{{ end }}{{ end }}`
+
+	// Type generated from a union type, holding the lexical value of any of
+	// its member types, with an accessor per member, which checks the value
+	// against the facets of the member.
+	unionType = `{{ define "UnionType" }}{{ $t := typeName .Name }}
+// {{ $t }} is generated from an XSD union type, holding the lexical value of
+// any of its member types
+type {{ $t }} string
+{{ range $m := unionMembers . }}
+// {{ $m.Accessor }} returns v as a {{ $m.Type }}, reporting whether it is one
+func (v {{ $t }}) {{ $m.Accessor }}() (x {{ $m.Type }}, ok bool) {
+	{{ $m.Parse }}
+}
+{{ end }}
+// UnmarshalText accepts the value of any of the member types of {{ $t }},
+// trying them in order{{ if not optional }}, or the empty value, which absent
+// optional fields are encoded as{{ end }}
+func (v *{{ $t }}) UnmarshalText(text []byte) error {
+	u := {{ $t }}(text)
+{{ if not optional }}	if u == "" {
+		*v = u
+		return nil
+	}
+{{ end }}{{ range $m := unionMembers . }}	if _, ok := u.{{ $m.Accessor }}(); ok {
+		*v = u
+		return nil
+	}
+{{ end }}	return fmt.Errorf("invalid {{ $t }} value: %q", text)
+}
+{{ if validated . }}{{ template "ValidateSimpleType" . }}{{ end }}{{ end }}`
 )
 
 var (
@@ -74,20 +432,40 @@ var (
 	initialisms = strings.NewReplacer(initialismPairs...)
 )
 
+// xsdtypeImport is the import path of the package of Go types for XSD
+// built-in data types, referred to by the generated code.
+const xsdtypeImport = "github.com/ivarg/goxsd/xsdtype"
+
 // Generator is responsible for generating Go structs based on a given XML
 // schema tree.
 type generator struct {
-	pkg      string
-	prefix   string
-	exported bool
+	pkg         string
+	prefix      string
+	exported    bool
+	choiceIface bool // generate sealed interfaces for choices
+	strict      bool // reject values not enumerated when decoding
+	validate    bool // generate Validate methods
+	optional    bool // generate optional elements and attributes as pointers
 
 	types map[string]struct{}
+	roots map[string]struct{}
+
+	// checked holds the names of the simple types whose values are checked
+	// against their facets by the accessors of the union types they are
+	// members of, so that they are validated even when not validating.
+	checked map[string]struct{}
 }
 
 func (g generator) do(out io.Writer, roots []*xmlTree) error {
 	g.types = make(map[string]struct{})
+	g.roots = make(map[string]struct{})
+	g.checked = make(map[string]struct{})
+	for _, e := range roots {
+		g.roots[e.Type] = struct{}{}
+		g.checkMembers(e)
+	}
 
-	tt, err := prepareTemplates(g.prefix, g.exported)
+	tt, err := prepareTemplates(g)
 	if err != nil {
 		return fmt.Errorf("could not prepare templates: %s", err)
 	}
@@ -98,6 +476,10 @@ func (g generator) do(out io.Writer, roots []*xmlTree) error {
 		fmt.Fprintf(&res, "// generated by goxsd; DO NOT EDIT\n\npackage %s\n\n", g.pkg)
 	}
 
+	// The import of the runtime package cannot be resolved by imports
+	// outside of its module; it is removed again if not used.
+	fmt.Fprintf(&res, "import %q\n\n", xsdtypeImport)
+
 	for _, e := range roots {
 		if err := g.execute(e, tt, &res); err != nil {
 			return err
@@ -122,16 +504,67 @@ func (g generator) do(out io.Writer, roots []*xmlTree) error {
 }
 
 func (g generator) execute(root *xmlTree, tt *template.Template, out io.Writer) error {
-	if _, ok := g.types[root.Name]; ok {
+	if root.Recursive { // generated with the enclosing element
+		return nil
+	}
+	if root.SimpleType != nil && !root.Cdata {
+		return g.executeSimpleType(root.SimpleType, tt, out)
+	}
+
+	name := root.Type
+	if _, ok := g.types[name]; ok {
 		return nil
 	}
-	if err := tt.Execute(out, root); err != nil {
+	if root.Choice {
+		if g.choiceIface {
+			if err := tt.ExecuteTemplate(out, "ChoiceType", root); err != nil {
+				return err
+			}
+		}
+	} else if root.Sequence {
+		if err := tt.ExecuteTemplate(out, "Sequence", root); err != nil {
+			return err
+		}
+	} else if root.Any {
+		if err := tt.ExecuteTemplate(out, "AnyType", root); err != nil {
+			return err
+		}
+	} else if root.Abstract {
+		if err := tt.ExecuteTemplate(out, "AbstractType", root); err != nil {
+			return err
+		}
+	} else if err := tt.Execute(out, root); err != nil {
 		return err
 	}
-	g.types[root.Name] = struct{}{}
+	g.types[name] = struct{}{}
+
+	if root.SimpleType != nil {
+		if err := g.executeSimpleType(root.SimpleType, tt, out); err != nil {
+			return err
+		}
+	}
+	for _, a := range root.Attribs {
+		if a.SimpleType != nil {
+			if err := g.executeSimpleType(a.SimpleType, tt, out); err != nil {
+				return err
+			}
+		}
+	}
+
+	if root.Embed != nil {
+		if err := g.execute(root.Embed, tt, out); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range root.Derived {
+		if err := g.execute(d, tt, out); err != nil {
+			return err
+		}
+	}
 
 	for _, e := range root.Children {
-		if !primitiveType(e) {
+		if !primitiveType(e) || e.SimpleType != nil {
 			if err := g.execute(e, tt, out); err != nil {
 				return err
 			}
@@ -141,15 +574,36 @@ func (g generator) execute(root *xmlTree, tt *template.Template, out io.Writer)
 	return nil
 }
 
-func prepareTemplates(prefix string, exported bool) (*template.Template, error) {
+func (g generator) executeSimpleType(t *xmlSimpleType, tt *template.Template, out io.Writer) error {
+	if t.Flat && !g.validated(t) {
+		return nil
+	}
+	if _, ok := g.types[t.Name]; ok {
+		return nil
+	}
+	g.types[t.Name] = struct{}{}
+	if t.Item != nil {
+		if err := g.executeSimpleType(t.Item, tt, out); err != nil {
+			return err
+		}
+	}
+	for _, m := range t.Members {
+		if m.SimpleType != nil {
+			if err := g.executeSimpleType(m.SimpleType, tt, out); err != nil {
+				return err
+			}
+		}
+	}
+	return tt.ExecuteTemplate(out, "SimpleType", t)
+}
+
+func prepareTemplates(g generator) (*template.Template, error) {
 	typeName := func(name string) string {
-		switch name {
-		case "bool", "string", "int", "float64", "time.Time":
-		default:
-			if prefix != "" {
-				name = prefix + strings.Title(name)
+		if goKind(name) == "" {
+			if g.prefix != "" {
+				name = g.prefix + strings.Title(name)
 			}
-			if exported {
+			if g.exported {
 				name = strings.Title(name)
 			}
 			name = lint(name)
@@ -157,11 +611,59 @@ func prepareTemplates(prefix string, exported bool) (*template.Template, error)
 		return name
 	}
 
+	vd := validation{typeName: typeName, choiceIface: g.choiceIface, optional: g.optional}
+
 	fmap := template.FuncMap{
 		"lint":      lint,
 		"lintTitle": lintTitle,
 		"typeName":  typeName,
-		"fieldType": fieldType,
+		"choiceIface": func() bool {
+			return g.choiceIface
+		},
+		"choiceList":    choiceList,
+		"simpleElement": simpleElement,
+		"enumConsts": func(t *xmlSimpleType) []enumConst {
+			return enumConsts(typeName(t.Name), t)
+		},
+		"parseText":  parseText,
+		"formatItem": formatItem,
+		"unionMembers": func(t *xmlSimpleType) []unionMember {
+			return unionMembers(typeName, t)
+		},
+		"strict": func() bool {
+			return g.strict
+		},
+		"validate": func() bool {
+			return g.validate
+		},
+		"optional": func() bool {
+			return g.optional
+		},
+		"runtimeType":          runtimeType,
+		"bigNumber":            bigNumber,
+		"optionalElem":         g.optionalElem,
+		"optionalAttr":         g.optionalAttr,
+		"itemUnmarshaler":      g.itemUnmarshaler,
+		"validated":            g.validated,
+		"validateFields":       vd.fields,
+		"validateAlternatives": vd.alternatives,
+		"validateFacets":       vd.facets,
+		"validateFunc":         vd.funcName,
+		"patternVar":           vd.patternVar,
+		"patternsOf":           patternsOf,
+		"xmlName":              xmlName,
+		"onlyEmbeds":           onlyEmbeds,
+		"unqualifiedWithin":    unqualifiedWithin,
+		"decodesChoices": func(e *xmlTree) bool {
+			return g.choiceIface && hasChoices(e)
+		},
+		"choiceDecoding": func(e *xmlTree) string {
+			return choiceDecoding(typeName, e)
+		},
+		"isRoot": func(e *xmlTree) bool {
+			_, ok := g.roots[e.Type]
+			return ok
+		},
 	}
 
 	tt := template.New("yyy").Funcs(fmap)
@@ -174,33 +676,498 @@ func prepareTemplates(prefix string, exported bool) (*template.Template, error)
 	if _, err := tt.Parse(child); err != nil {
 		return nil, err
 	}
+	if _, err := tt.Parse(choice); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(sequence); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(decodeChoices); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(encodeUnqualified); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(choiceType); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(simpleType); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(abstractType); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(anyType); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(listType); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(unionType); err != nil {
+		return nil, err
+	}
+	for _, v := range []string{validateStruct, validateChoice, validateAbstract, validateSimpleType, validateFlat, patterns} {
+		if _, err := tt.Parse(v); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tt.Parse(xmlname); err != nil {
+		return nil, err
+	}
 	if _, err := tt.Parse(elem); err != nil {
 		return nil, err
 	}
 	return tt, nil
 }
 
-// If this is a chardata field, the field type must point to a
-// struct, even if the element type is a built-in primitive.
-func fieldType(e *xmlTree) string {
-	if e.Cdata {
-		return e.Name
+// xmlName returns the name of an element or an attribute, as written in a
+// struct tag, qualified by its namespace if need be.
+func xmlName(ns string, qualified bool, name string) string {
+	if qualified && ns != "" {
+		return ns + " " + name
 	}
-	return e.Type
+	return name
 }
 
-func primitiveType(e *xmlTree) bool {
-	if e.Cdata {
-		return false
+// optionalElem reports whether the field generated from e is a pointer,
+// omitted when nil, as e is optional. An optional element of an abstract
+// type always is, as its holder must have a value to be encoded.
+func (g generator) optionalElem(e *xmlTree) bool {
+	return (g.optional || e.Abstract) && e.Optional
+}
+
+// optionalAttr reports whether the field generated from a is a pointer,
+// omitted when nil. In optional mode, a required attribute is one as well,
+// so that validation tells a missing one from a decoded zero value.
+func (g generator) optionalAttr(a xmlAttrib) bool {
+	return g.optional
+}
+
+// validated reports whether values of the simple type t are validated, as
+// they are when validating, or when checked by the accessors of a union.
+func (g generator) validated(t *xmlSimpleType) bool {
+	_, ok := g.checked[t.Name]
+	return g.validate || ok
+}
+
+// checkMembers records the member types of the union types of the values
+// held by e, and by the elements within it, as checked, along with the
+// simple types they are validated through.
+func (g generator) checkMembers(e *xmlTree) {
+	var union func(t *xmlSimpleType)
+	var check func(t *xmlSimpleType)
+	union = func(t *xmlSimpleType) {
+		if t == nil {
+			return
+		}
+		for _, m := range t.Members {
+			check(m.SimpleType)
+		}
+		union(t.Item)
+	}
+	check = func(t *xmlSimpleType) {
+		if t == nil {
+			return
+		}
+		if _, ok := g.checked[t.Name]; ok {
+			return
+		}
+		g.checked[t.Name] = struct{}{}
+		check(t.Item)
+		for _, m := range t.Members {
+			check(m.SimpleType)
+		}
+	}
+
+	union(e.SimpleType)
+	for _, a := range e.Attribs {
+		union(a.SimpleType)
+	}
+	if e.Embed != nil && !e.Embed.Recursive {
+		g.checkMembers(e.Embed)
+	}
+	for _, d := range e.Derived {
+		g.checkMembers(d)
 	}
+	for _, c := range e.Children {
+		if !c.Recursive {
+			g.checkMembers(c)
+		}
+	}
+}
+
+// itemUnmarshaler reports whether the items of the list type t are decoded
+// by the UnmarshalText method of their generated type, which checks them, as
+// that of a union type does, and that of an enumeration in strict mode.
+func (g generator) itemUnmarshaler(t *xmlSimpleType) bool {
+	it := t.Item
+	return it != nil && !it.Flat && (it.Union || g.strict && it.Values != nil)
+}
+
+// unqualifiedWithin reports whether the struct generated from e holds
+// unqualified elements of a namespace, as its own fields, or through the
+// choices, sequences or struct it embeds.
+func unqualifiedWithin(e *xmlTree) bool {
+	if e.Embed != nil && !e.Embed.Abstract && unqualifiedWithin(e.Embed) {
+		return true
+	}
+	for _, c := range e.Children {
+		if c.Choice || c.Sequence {
+			if unqualifiedWithin(c) {
+				return true
+			}
+		} else if c.Namespace != "" && !c.Qualified {
+			return true
+		}
+	}
+	return false
+}
 
-	switch e.Type {
-	case "bool", "string", "int", "float64", "time.Time":
+// choiceList reports whether a choice may hold more than one alternative,
+// either because the choice itself or one of its alternatives repeats.
+func choiceList(e *xmlTree) bool {
+	if e.List {
 		return true
 	}
+	for _, c := range e.Children {
+		if c.List {
+			return true
+		}
+	}
+	return false
+}
+
+// enumConst is a constant generated from an enumerated value.
+type enumConst struct {
+	Name  string
+	Value string // Go literal
+}
+
+// enumConsts returns the constants of an enumeration of the type named
+// typeName. Each is named by the type name followed by the enumerated value,
+// or its index if the value does not make a name. Values that are not valid
+// for the base type, or equal to an earlier value, are left out.
+func enumConsts(typeName string, t *xmlSimpleType) []enumConst {
+	var consts []enumConst
+	names := make(map[string]struct{})
+	values := make(map[string]struct{})
+	for i, v := range t.Values {
+		lit, ok := enumLiteral(t.Base, v)
+		if !ok {
+			continue
+		}
+		if _, ok := values[lit]; ok {
+			continue
+		}
+		values[lit] = struct{}{}
+
+		name := lint(typeName + identifier(v))
+		if _, ok := names[name]; ok || name == lint(typeName) {
+			name = lint(typeName) + strconv.Itoa(i)
+		}
+		names[name] = struct{}{}
+		consts = append(consts, enumConst{Name: name, Value: lit})
+	}
+	return consts
+}
+
+// enumLiteral returns the Go literal of an enumerated value of the given base
+// type, or the expression parsing it for decimals and integers of arbitrary
+// size, and whether the value is valid for the type.
+func enumLiteral(base, v string) (string, bool) {
+	if base == "string" {
+		return strconv.Quote(v), true
+	}
+
+	v = strings.TrimSpace(v)
+	switch goKind(base) {
+	case "bool":
+		switch v {
+		case "true", "1":
+			return "true", true
+		case "false", "0":
+			return "false", true
+		}
+	case "int":
+		if n, err := strconv.ParseInt(v, 10, bitSize(base)); err == nil {
+			return strconv.FormatInt(n, 10), true
+		}
+	case "uint":
+		if n, err := strconv.ParseUint(v, 10, bitSize(base)); err == nil {
+			return strconv.FormatUint(n, 10), true
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(v, bitSize(base)); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, bitSize(base)), true
+		}
+	case "decimal":
+		if decimalLiteral.MatchString(v) {
+			return fmt.Sprintf("xsdtype.MustParseDecimal(%q)", v), true
+		}
+	case "integer":
+		if integerLiteral.MatchString(v) {
+			return fmt.Sprintf("xsdtype.MustParseInteger(%q)", v), true
+		}
+	}
+	return "", false
+}
+
+// identifier turns an enumerated value into a title cased Go identifier, by
+// dropping anything but letters and digits.
+func identifier(v string) string {
+	words := strings.FieldsFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = strings.Title(w)
+	}
+	return strings.Join(words, "")
+}
+
+// parseText returns the statements parsing the string expression s into b, a
+// value of the given base type, executing the statement fail if s is not one.
+func parseText(base, s, fail string) string {
+	check := "\n\tif err != nil {\n\t\t" + fail + "\n\t}"
+	switch base {
+	case "string":
+		return "b := " + s
+	case "bool":
+		return "b, err := strconv.ParseBool(strings.TrimSpace(" + s + "))" + check
+	case "int":
+		return "b, err := strconv.Atoi(strings.TrimSpace(" + s + "))" + check
+	case "float64":
+		return "b, err := strconv.ParseFloat(strings.TrimSpace(" + s + "), 64)" + check
+	}
+
+	parse := "ParseInt"
+	switch goKind(base) {
+	case "int":
+	case "uint":
+		parse = "ParseUint"
+	case "float":
+		return fmt.Sprintf("n, err := strconv.ParseFloat(strings.TrimSpace(%s), %d)%s\n\tb := %s(n)", s, bitSize(base), check, base)
+	default:
+		return fmt.Sprintf("var b %s\n\tif err := b.UnmarshalText([]byte(%s)); err != nil {\n\t\t%s\n\t}", base, s, fail)
+	}
+	return fmt.Sprintf("n, err := strconv.%s(strings.TrimSpace(%s), 10, %d)%s\n\tb := %s(n)", parse, s, bitSize(base), check, base)
+}
+
+// unionMember is a member type of a union type, as generated: the accessor
+// returning a value of the union as a value of the member, and the body of
+// the accessor.
+type unionMember struct {
+	Accessor string
+	Type     string
+	Parse    string
+}
+
+// unionMembers returns the member types of the union type t. An accessor is
+// named for the Go type of its member, numbered if the type is not unique.
+// A value is one of a member of a simple type of its own only if it is valid
+// against the facets of that type.
+func unionMembers(typeName func(string) string, t *xmlSimpleType) []unionMember {
+	count := make(map[string]int)
+	for _, m := range t.Members {
+		count[m.Type]++
+	}
+
+	var members []unionMember
+	for i, m := range t.Members {
+		typ := typeName(m.Type)
+		name := typ
+		if j := strings.LastIndex(name, "."); j >= 0 {
+			name = name[j+1:]
+		}
+		accessor := "As" + lintTitle(name)
+		if count[m.Type] > 1 {
+			accessor += strconv.Itoa(i + 1)
+		}
+
+		st, base, valid := m.SimpleType, m.Type, "true"
+		if st != nil {
+			base = st.Base
+			valid = `len(x.validate("")) == 0`
+			if st.Flat {
+				valid = fmt.Sprintf(`len(validate%s(x, "")) == 0`, lintTitle(typeName(st.Name)))
+			}
+		}
+		parse := parseText(base, "string(v)", "return") + fmt.Sprintf("\n\tx = %s(b)\n\treturn x, %s", typ, valid)
+		if st != nil && (st.List || st.Union) {
+			parse = "if err := x.UnmarshalText([]byte(v)); err != nil {\n\t\treturn\n\t}\n\treturn x, " + valid
+		}
+		members = append(members, unionMember{Accessor: accessor, Type: typ, Parse: parse})
+	}
+	return members
+}
+
+// formatItem returns the statements formatting x, an item of a list of the
+// given base type, into items[i].
+func formatItem(base string) string {
+	switch goKind(base) {
+	case "string":
+		return "items[i] = string(x)"
+	case "bool", "int", "uint", "float":
+		return fmt.Sprintf("items[i] = fmt.Sprint(%s(x))", base)
+	}
+	return fmt.Sprintf("b, err := %s(x).MarshalText()\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\titems[i] = string(b)", base)
+}
+
+// contentOf returns the child elements, and choices, of the struct generated
+// from e, including those of the structs it embeds, in document order.
+func contentOf(e *xmlTree) []*xmlTree {
+	var content []*xmlTree
+	if e.Embed != nil && !e.Embed.Abstract {
+		content = contentOf(e.Embed)
+	}
+	return append(content, e.Children...)
+}
+
+// hasChoices reports whether the struct generated from e holds any choices,
+// including those of the structs it embeds.
+func hasChoices(e *xmlTree) bool {
+	for _, c := range contentOf(e) {
+		if c.Choice {
+			return true
+		}
+	}
 	return false
 }
 
+// choiceDecoding returns the statements decoding the element at start into
+// v, a struct generated from e holding choices as sealed interfaces, with x
+// holding the fields of v. Each alternative of a choice is decoded into the
+// holder of the choice by its element name, while other child elements are
+// left to x, and elements that are neither are an error.
+//
+// The elements of a sequence alternative are decoded into the sequence held
+// as the chosen alternative. When the choice repeats, an element starts a
+// new sequence unless it follows the element last decoded into the current
+// one, whose position is kept in a variable of its own.
+func choiceDecoding(typeName func(string) string, e *xmlTree) string {
+	var b, vars strings.Builder
+	var names []string
+	for i, c := range contentOf(e) {
+		if !c.Choice {
+			names = append(names, strconv.Quote(c.Name))
+			continue
+		}
+
+		f, holder := "v."+lintTitle(c.Name), typeName(c.Type)
+		list := choiceList(c)
+		pos := fmt.Sprintf("pos%d", i+1)
+		var alts []string
+		var seqs strings.Builder
+		for _, a := range c.Children {
+			if !a.Sequence {
+				alts = append(alts, strconv.Quote(a.Name))
+				continue
+			}
+			if list && !strings.Contains(vars.String(), pos+" ") {
+				fmt.Fprintf(&vars, "\tvar %s int // position in the sequence last decoded into %s\n", pos, f)
+			}
+			seq := typeName(a.Type)
+			for j, s := range a.Children {
+				fmt.Fprintf(&seqs, "\tcase %q:\n", s.Name)
+				if !list {
+					fmt.Fprintf(&seqs, "\t\tif %s == nil {\n\t\t\t%s = new(%s)\n\t\t}\n", f, f, holder)
+					fmt.Fprintf(&seqs, "\t\ts, _ := %s.Value.(%s)\n", f, seq)
+					fmt.Fprintf(&seqs, "\t\terr := d.DecodeElement(&s.%s, &child)\n", lintTitle(s.Name))
+					fmt.Fprintf(&seqs, "\t\t%s.Value = s\n\t\treturn true, err\n", f)
+					continue
+				}
+				follows := fmt.Sprintf("%s < %d", pos, j+1)
+				if s.List {
+					follows = fmt.Sprintf("%s <= %d", pos, j+1)
+				}
+				fmt.Fprintf(&seqs, "\t\ts, ok := %s{}, false\n", seq)
+				fmt.Fprintf(&seqs, "\t\tif n := len(%s); n > 0 && %s {\n\t\t\ts, ok = %s[n-1].Value.(%s)\n\t\t}\n", f, follows, f, seq)
+				fmt.Fprintf(&seqs, "\t\tif !ok {\n\t\t\t%s = append(%s, %s{})\n\t\t}\n", f, f, holder)
+				fmt.Fprintf(&seqs, "\t\t%s = %d\n", pos, j+1)
+				fmt.Fprintf(&seqs, "\t\terr := d.DecodeElement(&s.%s, &child)\n", lintTitle(s.Name))
+				fmt.Fprintf(&seqs, "\t\t%s[len(%s)-1].Value = s\n\t\treturn true, err\n", f, f)
+			}
+		}
+		if len(alts) > 0 {
+			fmt.Fprintf(&b, "\tcase %s:\n\t\treturn true, d.DecodeElement(&%s, &child)\n", strings.Join(alts, ", "), f)
+		}
+		b.WriteString(seqs.String())
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&b, "\tcase %s:\n\t\treturn false, nil\n", strings.Join(names, ", "))
+	}
+
+	return vars.String() +
+		"\treturn xsdtype.DecodeElement(d, start, &x, func(d *xml.Decoder, child xml.StartElement) (bool, error) {\n" +
+		"\t\tswitch child.Name.Local {\n" + indent(b.String()) + "\t\t}\n" +
+		"\t\treturn true, fmt.Errorf(\"unexpected element %s in element %s\", child.Name.Local, start.Name.Local)\n" +
+		"\t})\n"
+}
+
+// onlyEmbeds reports whether the struct generated from e has no fields but
+// the one embedding another struct, as for a global element of a named type.
+func onlyEmbeds(e *xmlTree) bool {
+	return e.Embed != nil && len(e.Attribs) == 0 && len(e.Children) == 0 && !e.Cdata
+}
+
+func primitiveType(e *xmlTree) bool {
+	return goKind(e.Type) != ""
+}
+
+// goKind returns the kind of the Go type of a built-in data type: "bool",
+// "string", "int", "uint", "float", "decimal", "integer", "time", "duration"
+// or "binary". Any other type, generated from the schema, is of no kind.
+func goKind(t string) string {
+	switch t {
+	case "bool", "string":
+		return t
+	case "int", "int8", "int16", "int32", "int64":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	case "xsdtype.Decimal":
+		return "decimal"
+	case "xsdtype.Integer":
+		return "integer"
+	case "xsdtype.DateTime", "xsdtype.Date", "xsdtype.Time", "xsdtype.GYearMonth",
+		"xsdtype.GYear", "xsdtype.GMonthDay", "xsdtype.GDay", "xsdtype.GMonth":
+		return "time"
+	case "xsdtype.Duration":
+		return "duration"
+	case "xsdtype.Base64Binary", "xsdtype.HexBinary":
+		return "binary"
+	}
+	return ""
+}
+
+// simpleElement reports whether the value of e is of a simple type, rather
+// than a struct.
+func simpleElement(e *xmlTree) bool {
+	return e.SimpleType != nil || goKind(e.Type) != ""
+}
+
+// runtimeType reports whether t is a type of the xsdtype package, whose
+// methods a type defined from it must forward to.
+func runtimeType(t string) bool {
+	return strings.HasPrefix(t, "xsdtype.")
+}
+
+// bigNumber reports whether t is a type of decimals or integers of arbitrary
+// size.
+func bigNumber(t string) bool {
+	kind := goKind(t)
+	return kind == "decimal" || kind == "integer"
+}
+
+// bitSize returns the size in bits of a numeric Go type.
+func bitSize(t string) int {
+	if n, err := strconv.Atoi(strings.TrimLeft(t, "intuflo")); err == nil {
+		return n
+	}
+	return 64
+}
+
 func lint(s string) string {
 	return dashToCamel(squish(initialisms.Replace(s)))
 }
@@ -1,20 +1,18 @@
-// Things not yet implemented:
-// - enforcing use="restricted" on attributes
-// - namespaces
-
 package main
 
 import (
+	"encoding/xml"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 )
 
 var (
-	output, pckg, prefix string
-	exported             bool
+	output, pckg, prefix                                                                 string
+	exported, choiceIface, strict, namedTypes, validate, optional, bigNumbers, embedBase bool
 
 	usage = `Usage: goxsd [options] <xsd_file>
 
@@ -23,6 +21,21 @@ Options:
   -p <package>  Package name [default: goxsd]
   -e            Generate exported structs [default: false]
   -x <prefix>   Struct name prefix [default: ""]
+  -c            Generate sealed interfaces for xs:choice, instead of
+                optional fields for each alternative [default: false]
+  -s            Reject values not enumerated by the schema when decoding
+                enumerated types [default: false]
+  -t            Generate named simple types as Go defined types, instead of
+                their built-in base types [default: false]
+  -v            Generate Validate methods checking values against the
+                constraints of the schema [default: false]
+  -n            Generate optional elements and attributes as pointer fields,
+                omitted when nil [default: false]
+  -b            Generate arbitrary-precision types for decimal and unbounded
+                integer types [default: false]
+  -d            Generate complex types derived by extension as structs
+                embedding the struct of their base type, instead of
+                copying its fields [default: false]
 
 goxsd is a tool for generating XML decoding/encoding Go structs, according
 to an XSD schema.
@@ -34,6 +47,13 @@ func main() {
 	flag.StringVar(&pckg, "p", "goxsd", "Name of the Go package")
 	flag.StringVar(&prefix, "x", "", "Name of the Go package")
 	flag.BoolVar(&exported, "e", false, "Generate exported structs")
+	flag.BoolVar(&choiceIface, "c", false, "Generate sealed interfaces for xs:choice")
+	flag.BoolVar(&strict, "s", false, "Reject values not enumerated when decoding")
+	flag.BoolVar(&namedTypes, "t", false, "Generate named simple types as Go defined types")
+	flag.BoolVar(&validate, "v", false, "Generate Validate methods")
+	flag.BoolVar(&optional, "n", false, "Generate optional elements and attributes as pointers")
+	flag.BoolVar(&bigNumbers, "b", false, "Generate arbitrary-precision types for decimals and integers")
+	flag.BoolVar(&embedBase, "d", false, "Generate derived types as structs embedding their base type")
 	flag.Parse()
 
 	if len(flag.Args()) != 1 {
@@ -44,7 +64,24 @@ func main() {
 
 	s, err := parseXSDFile(xsdFile)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	bldr := newBuilder(s)
+	bldr.namedTypes = namedTypes
+	bldr.bigNumbers = bigNumbers
+	bldr.embedBase = embedBase
+
+	roots, err := bldr.buildXML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if validate {
+		for _, w := range bldr.warnings {
+			fmt.Fprintln(os.Stderr, "warning:", w)
+		}
 	}
 
 	out := os.Stdout
@@ -55,15 +92,17 @@ func main() {
 		}
 	}
 
-	bldr := newBuilder(s)
-
 	gen := generator{
-		pkg:      pckg,
-		prefix:   prefix,
-		exported: exported,
+		pkg:         pckg,
+		prefix:      prefix,
+		exported:    exported,
+		choiceIface: choiceIface,
+		strict:      strict,
+		validate:    validate,
+		optional:    optional,
 	}
 
-	if err := gen.do(out, bldr.buildXML()); err != nil {
+	if err := gen.do(out, roots); err != nil {
 		fmt.Println("Code generation failed unexpectedly:", err.Error())
 		os.Exit(1)
 	}
@@ -72,29 +111,196 @@ func main() {
 // xmlTree is the representation of an XML element node in a tree. It
 // contains information about whether
 // - it is of a basic data type or a composite type (in which case its
-//   type equals its name)
+//   type is the unique name of the struct generated for it)
 // - if it represents a list of children to its parent
 // - if it has children of its own
 // - any attributes
-// - if the element contains any character data
+// - if the element contains any character data, and of what type
+// - if it refers back to the type of an enclosing element, in which case
+//   it has no attributes or children of its own
+//
+// Embed is the type embedded in the struct of the element: the named type of
+// a global element, or the base type of a derived complex type.
+//
+// Namespace is the target namespace of the schema declaring the element, and
+// Qualified tells whether the element name is qualified by it in documents.
+//
+// A tree with Choice set does not represent an element, but an xs:choice
+// within its parent. Its children are the alternatives of the choice. A tree
+// with Sequence set is such an alternative of more than one element, an
+// xs:sequence within the choice, with List set if the choice repeats.
+//
+// A global element of a named type gets a type of its own, embedding the
+// tree of its named type.
 type xmlTree struct {
-	Name     string
-	Type     string
-	List     bool
-	Cdata    bool
-	Attribs  []xmlAttrib
-	Children []*xmlTree
+	Name      string
+	Type      string
+	Namespace string
+	Qualified bool
+	List      bool
+	Cdata     bool
+	CdataType string
+	Choice    bool
+	Sequence  bool
+	Recursive bool
+	Embed     *xmlTree
+	Attribs   []xmlAttrib
+	Children  []*xmlTree
+
+	// Optional is set if the element may be absent, but not repeated.
+	Optional bool
+
+	// MinOccurs and MaxOccurs bound the number of occurrences of a list,
+	// with MaxOccurs zero if unbounded.
+	MinOccurs int
+	MaxOccurs int
+
+	// SimpleType is the simple type of the value, or character data, of
+	// the element, if it is generated as a type of its own.
+	SimpleType *xmlSimpleType
+
+	// Abstract is set if the element is of an abstract type, so that it
+	// holds a value of any of the Derived types, as named by xsi:type.
+	Abstract bool
+	Derived  []*xmlTree
+
+	// Named is set if the struct of the element is generated from a named
+	// complex type, rather than from the element itself.
+	Named bool
+
+	// Any is set if the element is of xs:anyType, so that its struct holds
+	// the attributes and content of the element as they are.
+	Any bool
 }
 
 type xmlAttrib struct {
-	Name string
-	Type string
+	Name       string
+	Type       string
+	Namespace  string
+	Qualified  bool
+	Required   bool
+	SimpleType *xmlSimpleType
+}
+
+// xmlSimpleType is a simple type generated as a Go type of its own, based on
+// the Go type of a built-in data type. Enumerations are generated so, as are
+// any named simple types when asked for. Other simple types with facets are
+// flat; their values are of the Go type of the built-in data type, and only
+// their facets are generated, for validation. List types are generated as
+// slices of their item type, with Base the Go type of the built-in data type
+// of the items, and length facets counting the items. Union types are
+// generated as strings, holding the lexical value of any of their members.
+type xmlSimpleType struct {
+	Name       string
+	Base       string
+	Values     []string
+	Facets     []xmlFacets // one per restriction, from the built-in data type
+	WhiteSpace string      // "preserve", "replace", "collapse" or empty
+	Flat       bool
+	List       bool
+	ItemType   string         // Go type of the items of a list type
+	Item       *xmlSimpleType // simple type of the items, if any
+	Union      bool
+	Members    []xmlMember // member types of a union type, in order
+}
+
+// xmlMember is a member type of a union type.
+type xmlMember struct {
+	Type       string         // Go type of the values of the member
+	SimpleType *xmlSimpleType // simple type of the member, if any
+}
+
+// goType returns the Go type of the values of the simple type.
+func (t *xmlSimpleType) goType() string {
+	if t.Flat {
+		return t.Base
+	}
+	return t.Name
+}
+
+// xmlFacets are the constraining facets of a single restriction of a simple
+// type, apart from enumeration and whiteSpace. Facets not given are empty.
+// Any of the patterns may be matched.
+type xmlFacets struct {
+	Length, MinLength, MaxLength string
+	Patterns                     []string
+	MinInclusive, MaxInclusive   string
+	MinExclusive, MaxExclusive   string
+	TotalDigits, FractionDigits  string
 }
 
+// builder builds xmlTree hierarchies from a set of XSD schemas. The global
+// components of the schemas are registered by their namespace qualified
+// names.
 type builder struct {
 	schemas    []xsdSchema
-	complTypes map[string]xsdComplexType
-	simplTypes map[string]xsdSimpleType
+	elements   map[xml.Name]xsdElement
+	attributes map[xml.Name]xsdAttribute
+	complTypes map[xml.Name]xsdComplexType
+	simplTypes map[xml.Name]xsdSimpleType
+	groups     map[xml.Name]xsdGroup
+	attrGroups map[xml.Name]xsdAttributeGroup
+
+	// building holds the elements under construction, by the position of
+	// their complex type, to detect recursive type definitions.
+	building map[xsdPos]*xmlTree
+
+	// built holds the elements built from complex types, by the position of
+	// the type, so that each type is built once however often it is used.
+	built map[xsdPos]*xmlTree
+
+	// expanding holds the names of the groups, or attribute groups, being
+	// expanded within the type under construction, outermost first, to
+	// detect circular groups.
+	expanding []xml.Name
+
+	// typeNames holds the unique names of the types generated from complex
+	// types, global elements and choices, by the position of the component.
+	typeNames map[xsdPos]string
+	taken     map[string]struct{}
+
+	// holderNames holds the unique names of the types holding values of the
+	// types derived from abstract complex types, by the position of the
+	// abstract type.
+	holderNames map[xsdPos]string
+
+	// anyType is the unique name of the type holding the elements of
+	// xs:anyType, once taken.
+	anyType string
+
+	// simpleTypes holds the simple types generated as types of their own,
+	// by the position of their definition.
+	simpleTypes map[xsdPos]*xmlSimpleType
+
+	// warnings holds the problems found in components that are generated
+	// nonetheless, once per component.
+	warnings []error
+	warned   map[xsdPos]struct{}
+
+	namedTypes bool // generate named simple types as types of their own
+	bigNumbers bool // hold decimals and unbounded integers exactly
+	embedBase  bool // embed the structs of base types in derived ones
+}
+
+// predeclared holds the predeclared identifiers of Go, which generated types
+// must not shadow.
+var predeclared = []string{
+	"any", "bool", "byte", "comparable", "complex64", "complex128", "error",
+	"float32", "float64", "int", "int8", "int16", "int32", "int64", "rune",
+	"string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+	"true", "false", "iota", "nil",
+	"append", "cap", "clear", "close", "complex", "copy", "delete", "imag",
+	"len", "make", "max", "min", "new", "panic", "print", "println", "real",
+	"recover",
+}
+
+// takenNames returns the names taken before any type is generated.
+func takenNames() map[string]struct{} {
+	taken := make(map[string]struct{})
+	for _, n := range predeclared {
+		taken[n] = struct{}{}
+	}
+	return taken
 }
 
 // newBuilder creates a new initialized builder populated with the given
@@ -102,212 +308,1288 @@ type builder struct {
 func newBuilder(schemas []xsdSchema) *builder {
 	return &builder{
 		schemas:    schemas,
-		complTypes: make(map[string]xsdComplexType),
-		simplTypes: make(map[string]xsdSimpleType),
+		elements:   make(map[xml.Name]xsdElement),
+		attributes: make(map[xml.Name]xsdAttribute),
+		complTypes: make(map[xml.Name]xsdComplexType),
+		simplTypes: make(map[xml.Name]xsdSimpleType),
+		groups:     make(map[xml.Name]xsdGroup),
+		attrGroups: make(map[xml.Name]xsdAttributeGroup),
+		building:   make(map[xsdPos]*xmlTree),
+		built:      make(map[xsdPos]*xmlTree),
+		typeNames:  make(map[xsdPos]string),
+		taken:      takenNames(),
+
+		holderNames: make(map[xsdPos]string),
+		simpleTypes: make(map[xsdPos]*xmlSimpleType),
+		warned:      make(map[xsdPos]struct{}),
 	}
 }
 
+// warnf records a warning about the component at pos, unless one has been
+// recorded already.
+func (b *builder) warnf(pos xsdPos, component, format string, args ...interface{}) {
+	if _, ok := b.warned[pos]; ok {
+		return
+	}
+	b.warned[pos] = struct{}{}
+	b.warnings = append(b.warnings, buildErrorf(pos, component, format, args...))
+}
+
+// buildError is an error in building from a schema component, located by
+// the position of the component in its schema.
+type buildError struct {
+	xsdPos
+	component string
+	msg       string
+}
+
+func (e *buildError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.component, e.msg)
+}
+
+func buildErrorf(pos xsdPos, component, format string, args ...interface{}) error {
+	return &buildError{xsdPos: pos, component: component, msg: fmt.Sprintf(format, args...)}
+}
+
 // buildXML generates and returns a tree of xmlTree objects based on a set of
 // parsed XSD schemas.
-func (b *builder) buildXML() []*xmlTree {
+func (b *builder) buildXML() ([]*xmlTree, error) {
 	var roots []xsdElement
 	for _, s := range b.schemas {
+		ns := s.TargetNamespace
 		for _, e := range s.Elements {
 			roots = append(roots, e)
+			b.elements[xml.Name{Space: ns, Local: e.Name}] = e
+		}
+		for _, a := range s.Attributes {
+			b.attributes[xml.Name{Space: ns, Local: a.Name}] = a
 		}
 		for _, t := range s.ComplexTypes {
-			b.complTypes[t.Name] = t
+			b.complTypes[xml.Name{Space: ns, Local: t.Name}] = t
 		}
 		for _, t := range s.SimpleTypes {
-			b.simplTypes[t.Name] = t
+			b.simplTypes[xml.Name{Space: ns, Local: t.Name}] = t
+		}
+		for _, g := range s.Groups {
+			b.groups[xml.Name{Space: ns, Local: g.Name}] = g
+		}
+		for _, g := range s.AttributeGroups {
+			b.attrGroups[xml.Name{Space: ns, Local: g.Name}] = g
+		}
+	}
+
+	// Global elements are named first, as their names are used in documents,
+	// and then named types, so that anonymous types are named after them.
+	for _, e := range roots {
+		name := b.typeName(e.xsdPos, e.Name)
+		if e.ComplexType != nil {
+			b.typeNames[e.ComplexType.xsdPos] = name
+		}
+	}
+	for _, s := range b.schemas {
+		for _, t := range s.ComplexTypes {
+			b.typeName(t.xsdPos, t.Name)
 		}
 	}
 
 	var xelems []*xmlTree
 	for _, e := range roots {
-		xelems = append(xelems, b.buildFromElement(e))
+		xelem, err := b.buildFromGlobalElement(e)
+		if err != nil {
+			return nil, err
+		}
+		xelems = append(xelems, xelem)
 	}
 
-	return xelems
+	return xelems, nil
+}
+
+// typeName returns the unique name of the type generated from the component
+// at pos, claiming one derived from name the first time the component is met.
+// Names differing only in case are told apart, as they may be title cased.
+func (b *builder) typeName(pos xsdPos, name string) string {
+	if n, ok := b.typeNames[pos]; ok {
+		return n
+	}
+	n := b.uniqueName(name)
+	b.typeNames[pos] = n
+	return n
+}
+
+// holderName returns the unique name of the type holding values of the types
+// derived from the abstract complex type t, such as anyShape for shape.
+func (b *builder) holderName(t xsdComplexType) string {
+	if n, ok := b.holderNames[t.xsdPos]; ok {
+		return n
+	}
+	n := b.uniqueName("any" + strings.ToUpper(t.Name[:1]) + t.Name[1:])
+	b.holderNames[t.xsdPos] = n
+	return n
+}
+
+// anyTypeName returns the unique name of the type holding the elements of
+// xs:anyType.
+func (b *builder) anyTypeName() string {
+	if b.anyType == "" {
+		b.anyType = b.uniqueName("anyType")
+	}
+	return b.anyType
+}
+
+// uniqueName returns name, with a number appended if it is already taken,
+// and takes it.
+func (b *builder) uniqueName(name string) string {
+	n := name
+	for i := 2; ; i++ {
+		if _, ok := b.taken[strings.ToLower(n)]; !ok {
+			break
+		}
+		n = name + strconv.Itoa(i)
+	}
+	b.taken[strings.ToLower(n)] = struct{}{}
+	return n
+}
+
+// buildFromGlobalElement builds an xmlTree from a global element. Unless the
+// element has an anonymous complex type, its type is wrapped in a type of its
+// own, named after the element, to hold the name of the element.
+func (b *builder) buildFromGlobalElement(e xsdElement) (*xmlTree, error) {
+	xelem, err := b.buildFromElement(e)
+	if err != nil {
+		return nil, err
+	}
+
+	name := b.typeNames[e.xsdPos]
+	if xelem.Type == name {
+		return xelem, nil
+	}
+
+	root := &xmlTree{
+		Name:      e.Name,
+		Type:      name,
+		Namespace: e.Namespace,
+		Qualified: e.Qualified,
+	}
+	// The struct of a complex type is embedded, unless the type has simple
+	// content only, which is held as character data, like a simple type.
+	_, complex := b.findType(e.Type).(xsdComplexType)
+	if (complex || xelem.Any) && !simpleElement(xelem) {
+		root.Embed = xelem
+	} else {
+		root.Cdata = true
+		root.CdataType = xelem.Type
+		root.SimpleType = xelem.SimpleType
+	}
+	return root, nil
 }
 
 // buildFromElement builds an xmlTree from an xsdElement, recursively
 // traversing the XSD type information to build up an XML element hierarchy.
-func (b *builder) buildFromElement(e xsdElement) *xmlTree {
-	xelem := &xmlTree{Name: e.Name, Type: e.Name}
+func (b *builder) buildFromElement(e xsdElement) (*xmlTree, error) {
+	if e.Ref != "" {
+		var err error
+		if e, err = b.findElement(e); err != nil {
+			return nil, err
+		}
+	}
+
+	xelem := &xmlTree{
+		Name:      e.Name,
+		Namespace: e.Namespace,
+		Qualified: e.Qualified,
+	}
 
 	if e.isList() {
 		xelem.List = true
+		xelem.MinOccurs, xelem.MaxOccurs = listOccurs(e.Min, e.Max)
+	} else if min, _ := occurs(e.Min, e.Max); min == 0 {
+		xelem.Optional = true
 	}
 
 	if !e.inlineType() {
+		var err error
 		switch t := b.findType(e.Type).(type) {
 		case xsdComplexType:
-			b.buildFromComplexType(xelem, t)
+			if t.isAbstract() {
+				return b.buildFromAbstractType(xelem, t)
+			}
+			return b.buildFromElementType(xelem, t)
 		case xsdSimpleType:
-			b.buildFromSimpleType(xelem, t)
+			err = b.buildFromSimpleType(xelem, t)
 		case string:
 			xelem.Type = t
+			if t == "anyType" {
+				xelem.Type, xelem.Any = b.anyTypeName(), true
+			}
+		case unresolvedType:
+			err = buildErrorf(e.xsdPos, fmt.Sprintf("element %q", e.Name), "no such type %q", e.Type)
 		}
-		return xelem
+		return xelem, err
 	}
 
 	if e.ComplexType != nil { // inline complex type
-		b.buildFromComplexType(xelem, *e.ComplexType)
-		return xelem
+		return b.buildFromElementType(xelem, *e.ComplexType)
 	}
 
 	if e.SimpleType != nil { // inline simple type
-		b.buildFromSimpleType(xelem, *e.SimpleType)
-		return xelem
+		return xelem, b.buildFromSimpleType(xelem, *e.SimpleType)
+	}
+
+	// An element without a type is of an empty type of its own.
+	return b.buildFromElementType(xelem, xsdComplexType{xsdPos: e.xsdPos})
+}
+
+// buildFromElementType builds xelem from the complex type of its element,
+// named by the type, or by the element for an anonymous type. An element of
+// the same type as one of its enclosing elements is not built again, but
+// marked as recursive and given the type of the enclosing element. Nor is a
+// type built for any element but the first; the others get what was built.
+//
+// A complex type with simple content but no attributes results in an element
+// of the simple type.
+func (b *builder) buildFromElementType(xelem *xmlTree, t xsdComplexType) (*xmlTree, error) {
+	if enclosing, ok := b.building[t.xsdPos]; ok {
+		xelem.Type = enclosing.Type
+		xelem.Recursive = true
+		return xelem, nil
+	}
+
+	if built, ok := b.built[t.xsdPos]; ok {
+		x := *built
+		x.Name, x.Namespace, x.Qualified = xelem.Name, xelem.Namespace, xelem.Qualified
+		x.List, x.MinOccurs, x.MaxOccurs = xelem.List, xelem.MinOccurs, xelem.MaxOccurs
+		x.Optional = xelem.Optional
+		*xelem = x
+		return xelem, nil
+	}
+
+	b.building[t.xsdPos] = xelem
+	defer delete(b.building, t.xsdPos)
+
+	// A group may be expanded again within the type of an element of its
+	// own; the type is then recursive rather than the group circular.
+	expanding := b.expanding
+	b.expanding = nil
+	defer func() { b.expanding = expanding }()
+
+	xelem.Type = b.typeName(t.xsdPos, xelem.Name)
+	xelem.Named = t.Name != ""
+	if err := b.buildFromComplexType(xelem, t); err != nil {
+		return nil, err
+	}
+
+	if xelem.CdataType != "" {
+		if len(xelem.Attribs) == 0 {
+			xelem.Type, xelem.CdataType = xelem.CdataType, ""
+			xelem.Named = false
+		} else {
+			xelem.Cdata = true
+		}
+	}
+	built := *xelem
+	b.built[t.xsdPos] = &built
+	return xelem, nil
+}
+
+// buildFromAbstractType builds xelem, an element of the abstract complex type
+// t, as holding a value of any of the types derived from t that are not
+// abstract themselves. The type of the value is named by the xsi:type
+// attribute of the element.
+func (b *builder) buildFromAbstractType(xelem *xmlTree, t xsdComplexType) (*xmlTree, error) {
+	xelem.Type = b.holderName(t)
+	xelem.Abstract = true
+	for _, s := range b.schemas {
+		for _, dt := range s.ComplexTypes {
+			if dt.isAbstract() || !b.derivesFrom(dt, t) {
+				continue
+			}
+			d := &xmlTree{Name: dt.Name, Namespace: s.TargetNamespace}
+			if _, err := b.buildFromElementType(d, dt); err != nil {
+				return nil, err
+			}
+			xelem.Derived = append(xelem.Derived, d)
+		}
 	}
+	return xelem, nil
+}
 
-	return xelem
+// derivesFrom reports whether the complex type t is derived from the complex
+// type base, directly or through other types, by extension or restriction.
+func (b *builder) derivesFrom(t, base xsdComplexType) bool {
+	var e *xsdExtension
+	var r *xsdRestriction
+	if c := t.ComplexContent; c != nil {
+		e, r = c.Extension, c.Restriction
+	}
+	if c := t.SimpleContent; c != nil {
+		e, r = c.Extension, c.Restriction
+	}
+
+	var name string
+	switch {
+	case e != nil:
+		name = e.Base
+	case r != nil:
+		name = r.Base
+	}
+	bt, ok := b.findType(name).(xsdComplexType)
+	if !ok {
+		return false
+	}
+	return bt.xsdPos == base.xsdPos || b.derivesFrom(bt, base)
 }
 
 // buildFromComplexType takes an xmlTree and an xsdComplexType, containing
 // XSD type information for xmlTree enrichment.
-func (b *builder) buildFromComplexType(xelem *xmlTree, t xsdComplexType) {
-	if t.Sequence != nil { // Does the element have children?
-		for _, e := range t.Sequence {
-			xelem.Children = append(xelem.Children, b.buildFromElement(e))
+func (b *builder) buildFromComplexType(xelem *xmlTree, t xsdComplexType) error {
+	if g := t.modelGroup(); g != nil { // Does the element have children?
+		if err := b.buildFromModelGroup(xelem, *g); err != nil {
+			return err
 		}
 	}
 
 	if t.Attributes != nil {
-		b.buildFromAttributes(xelem, t.Attributes)
+		if err := b.buildFromAttributes(xelem, t.Attributes); err != nil {
+			return err
+		}
+	}
+
+	if t.AttributeGroups != nil {
+		if err := b.buildFromAttributeGroups(xelem, t.AttributeGroups); err != nil {
+			return err
+		}
 	}
 
 	if t.ComplexContent != nil {
-		b.buildFromComplexContent(xelem, *t.ComplexContent)
+		if err := b.buildFromComplexContent(xelem, *t.ComplexContent); err != nil {
+			return err
+		}
 	}
 
 	if t.SimpleContent != nil {
-		b.buildFromSimpleContent(xelem, *t.SimpleContent)
+		return b.buildFromSimpleContent(xelem, *t.SimpleContent)
 	}
+
+	return nil
 }
 
 // buildFromSimpleType assumes restriction child and fetches the base value,
-// assuming that value is of a XSD built-in data type.
-func (b *builder) buildFromSimpleType(xelem *xmlTree, t xsdSimpleType) {
-	xelem.Type = b.findType(t.Restriction.Base).(string)
+// following any simple types down to a XSD built-in data type, unless the
+// simple type is generated as a type of its own.
+func (b *builder) buildFromSimpleType(xelem *xmlTree, t xsdSimpleType) error {
+	typ, st, err := b.simpleType(t, xelem.Name)
+	if err != nil {
+		return err
+	}
+	xelem.Type, xelem.SimpleType = typ, st
+	return nil
+}
+
+// simpleType returns the Go type of a simple type, along with its definition
+// if it is generated as a type of its own. That is the case for enumerations
+// of string, numeric and boolean values, decimals and integers of arbitrary
+// size included, and for restrictions of such enumerations. With namedTypes
+// set, it is also the case for any other named simple type. An anonymous
+// simple type is named after name, the name of its element or attribute.
+func (b *builder) simpleType(t xsdSimpleType, name string) (string, *xmlSimpleType, error) {
+	if st, ok := b.simpleTypes[t.xsdPos]; ok {
+		return st.goType(), st, nil
+	}
+
+	if l := b.listOf(t); l != nil {
+		return b.listType(t, *l, name)
+	}
+	if u := b.unionOf(t); u != nil {
+		return b.unionType(t, *u, name)
+	}
+
+	base, err := b.simpleTypeBase(t)
+	if err != nil {
+		return "", nil, err
+	}
+
+	enum := len(t.Restriction.Enumeration) > 0
+	if !enum {
+		if bt, ok := b.findType(t.Restriction.Base).(xsdSimpleType); ok {
+			typ, st, err := b.simpleType(bt, bt.Name)
+			if err != nil || (st != nil && st.Values != nil) {
+				return typ, st, err
+			}
+		}
+	}
+
+	switch goKind(base) {
+	case "bool", "string", "int", "uint", "float", "decimal", "integer":
+	default:
+		enum = false
+	}
+	facets, ws := b.facets(t)
+	flat := !enum && !(b.namedTypes && t.Name != "")
+	if flat && len(facets) == 0 {
+		return base, nil, nil
+	}
+
+	if t.Name != "" {
+		name = t.Name
+	}
+	st := &xmlSimpleType{
+		Name:       b.typeName(t.xsdPos, name),
+		Base:       base,
+		Facets:     facets,
+		WhiteSpace: ws,
+		Flat:       flat,
+	}
+	if enum {
+		for _, e := range t.Restriction.Enumeration {
+			st.Values = append(st.Values, e.Value)
+		}
+	}
+	b.simpleTypes[t.xsdPos] = st
+	return st.goType(), st, nil
+}
+
+// facets returns the facets of a simple type, one set per restriction from
+// the built-in data type down to t, along with the whiteSpace facet in effect.
+func (b *builder) facets(t xsdSimpleType) ([]xmlFacets, string) {
+	var facets []xmlFacets
+	var ws string
+	if bt, ok := b.findType(t.Restriction.Base).(xsdSimpleType); ok {
+		facets, ws = b.facets(bt)
+	}
+
+	r := t.Restriction
+	value := func(f *xsdFacet) string {
+		if f == nil {
+			return ""
+		}
+		return f.Value
+	}
+	f := xmlFacets{
+		Length:         value(r.Length),
+		MinLength:      value(r.MinLength),
+		MaxLength:      value(r.MaxLength),
+		MinInclusive:   value(r.MinInclusive),
+		MaxInclusive:   value(r.MaxInclusive),
+		MinExclusive:   value(r.MinExclusive),
+		MaxExclusive:   value(r.MaxExclusive),
+		TotalDigits:    value(r.TotalDigits),
+		FractionDigits: value(r.FractionDigits),
+	}
+	for _, p := range r.Patterns {
+		f.Patterns = append(f.Patterns, p.Value)
+	}
+	if _, ok := xsdRegexp(f.Patterns); len(f.Patterns) > 0 && !ok {
+		component := "anonymous simpleType"
+		if t.Name != "" {
+			component = fmt.Sprintf("simpleType %q", t.Name)
+		}
+		b.warnf(t.xsdPos, component, "pattern %q cannot be translated into a Go regular expression, and is not validated",
+			strings.Join(f.Patterns, " | "))
+	}
+	if !reflect.DeepEqual(f, xmlFacets{}) {
+		facets = append(facets, f)
+	}
+	if r.WhiteSpace != nil {
+		ws = r.WhiteSpace.Value
+	}
+	return facets, ws
+}
+
+// listOf returns the list type derivation of t, following its restriction
+// bases, or nil if t is not a list type.
+func (b *builder) listOf(t xsdSimpleType) *xsdList {
+	if t.List != nil {
+		return t.List
+	}
+	if bt, ok := b.findType(t.Restriction.Base).(xsdSimpleType); ok {
+		return b.listOf(bt)
+	}
+	return nil
+}
+
+// listType returns the Go type of the list type t, derived by l, or by a
+// restriction of a list type. The item type of an anonymous list type is
+// named for the list.
+func (b *builder) listType(t xsdSimpleType, l xsdList, name string) (string, *xmlSimpleType, error) {
+	if t.Name != "" {
+		name = t.Name
+	}
+	component := fmt.Sprintf("list type %q", name)
+
+	var itemType string
+	var item *xmlSimpleType
+	var err error
+	if l.SimpleType != nil {
+		itemType, item, err = b.simpleType(*l.SimpleType, name+"Item")
+	} else {
+		switch it := b.findType(l.ItemType).(type) {
+		case xsdSimpleType:
+			itemType, item, err = b.simpleType(it, it.Name)
+		case xsdComplexType:
+			err = buildErrorf(t.xsdPos, component, "item type %q is a complex type", l.ItemType)
+		case unresolvedType:
+			err = buildErrorf(t.xsdPos, component, "no such item type %q", l.ItemType)
+		default:
+			itemType = it.(string)
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if itemType == "" {
+		return "", nil, buildErrorf(t.xsdPos, component, "no item type")
+	}
+	if item != nil && item.List {
+		return "", nil, buildErrorf(t.xsdPos, component, "item type %q is a list type", l.ItemType)
+	}
+
+	base := itemType
+	if item != nil {
+		base = item.Base
+	}
+	facets, _ := b.facets(t)
+	st := &xmlSimpleType{
+		Name:     b.typeName(t.xsdPos, name),
+		Base:     base,
+		Facets:   facets,
+		List:     true,
+		ItemType: itemType,
+		Item:     item,
+	}
+	b.simpleTypes[t.xsdPos] = st
+	return st.Name, st, nil
+}
+
+// unionOf returns the union type derivation of t, following its restriction
+// bases, or nil if t is not a union type.
+func (b *builder) unionOf(t xsdSimpleType) *xsdUnion {
+	if t.Union != nil {
+		return t.Union
+	}
+	if bt, ok := b.findType(t.Restriction.Base).(xsdSimpleType); ok {
+		return b.unionOf(bt)
+	}
+	return nil
+}
+
+// unionType returns the Go type of the union type t, derived by u, or by a
+// restriction of a union type. The inline member types of an anonymous union
+// type are named for the union, and numbered by their position among the
+// members.
+func (b *builder) unionType(t xsdSimpleType, u xsdUnion, name string) (string, *xmlSimpleType, error) {
+	if t.Name != "" {
+		name = t.Name
+	}
+	component := fmt.Sprintf("union type %q", name)
+	st := &xmlSimpleType{
+		Name:  b.typeName(t.xsdPos, name),
+		Base:  "string",
+		Union: true,
+	}
+
+	for _, m := range strings.Fields(u.MemberTypes) {
+		var member xmlMember
+		var err error
+		switch mt := b.findType(m).(type) {
+		case xsdSimpleType:
+			member.Type, member.SimpleType, err = b.simpleType(mt, mt.Name)
+		case xsdComplexType:
+			err = buildErrorf(t.xsdPos, component, "member type %q is a complex type", m)
+		case unresolvedType:
+			err = buildErrorf(t.xsdPos, component, "no such member type %q", m)
+		default:
+			member.Type = mt.(string)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		st.Members = append(st.Members, member)
+	}
+	for _, mt := range u.SimpleTypes {
+		var member xmlMember
+		var err error
+		member.Type, member.SimpleType, err = b.simpleType(mt, fmt.Sprintf("%sMember%d", name, len(st.Members)+1))
+		if err != nil {
+			return "", nil, err
+		}
+		st.Members = append(st.Members, member)
+	}
+	if len(st.Members) == 0 {
+		return "", nil, buildErrorf(t.xsdPos, component, "no member types")
+	}
+
+	b.simpleTypes[t.xsdPos] = st
+	return st.Name, st, nil
 }
 
-func (b *builder) buildFromComplexContent(xelem *xmlTree, c xsdComplexContent) {
+// simpleTypeBase follows the restriction bases of a simple type down to the
+// built-in data type it derives from, and returns its Go type.
+func (b *builder) simpleTypeBase(t xsdSimpleType) (string, error) {
+	component := "anonymous simpleType"
+	if t.Name != "" {
+		component = fmt.Sprintf("simpleType %q", t.Name)
+	}
+	if t.Restriction.Base == "" {
+		return "", buildErrorf(t.xsdPos, component, "only derivation by restriction of a named base type is supported")
+	}
+
+	switch bt := b.findType(t.Restriction.Base).(type) {
+	case xsdSimpleType:
+		return b.simpleTypeBase(bt)
+	case xsdComplexType:
+		return "", buildErrorf(t.xsdPos, component, "restriction base %q is a complex type", t.Restriction.Base)
+	case unresolvedType:
+		return "", buildErrorf(t.xsdPos, component, "no such restriction base %q", t.Restriction.Base)
+	default:
+		return bt.(string), nil
+	}
+}
+
+func (b *builder) buildFromComplexContent(xelem *xmlTree, c xsdComplexContent) error {
 	if c.Extension != nil {
-		b.buildFromExtension(xelem, c.Extension)
+		return b.buildFromExtension(xelem, c.Extension)
+	}
+
+	if c.Restriction != nil {
+		return b.buildFromComplexRestriction(xelem, c.Restriction)
+	}
+
+	return nil
+}
+
+// buildFromComplexRestriction restricts a complex type of complex content.
+// The restriction declares every particle it keeps, so only those are built.
+func (b *builder) buildFromComplexRestriction(xelem *xmlTree, r *xsdRestriction) error {
+	if _, ok := b.findType(r.Base).(xsdSimpleType); ok {
+		return buildErrorf(r.xsdPos, fmt.Sprintf("restriction of %q", r.Base), "base type is a simple type")
+	}
+
+	if g := r.modelGroup(); g != nil {
+		if err := b.buildFromModelGroup(xelem, *g); err != nil {
+			return err
+		}
+	}
+	return b.buildFromRestrictedAttributes(xelem, r)
+}
+
+// buildFromRestrictedAttributes adds the attributes of the base type of the
+// restriction r to xelem, less those r prohibits, and replaced by those r
+// declares again.
+func (b *builder) buildFromRestrictedAttributes(xelem *xmlTree, r *xsdRestriction) error {
+	base := &xmlTree{}
+	if t, ok := b.findType(r.Base).(xsdComplexType); ok {
+		if err := b.buildFromBaseAttributes(base, t); err != nil {
+			return err
+		}
+	}
+
+	own := &xmlTree{}
+	prohibited := make(map[xml.Name]bool)
+	var attrs []xsdAttribute
+	for _, a := range r.Attributes {
+		if a.Use == "prohibited" && a.Ref != "" {
+			var err error
+			if a, err = b.findAttribute(a); err != nil {
+				return err
+			}
+		}
+		if a.Use == "prohibited" {
+			prohibited[attrName(a.Namespace, a.Qualified, a.Name)] = true
+		} else {
+			attrs = append(attrs, a)
+		}
+	}
+	if err := b.buildFromAttributes(own, attrs); err != nil {
+		return err
+	}
+	if err := b.buildFromAttributeGroups(own, r.AttributeGroups); err != nil {
+		return err
+	}
+
+	for _, a := range base.Attribs {
+		name := attrName(a.Namespace, a.Qualified, a.Name)
+		if prohibited[name] {
+			continue
+		}
+		for i, o := range own.Attribs {
+			if attrName(o.Namespace, o.Qualified, o.Name) == name {
+				a = o
+				own.Attribs = append(own.Attribs[:i], own.Attribs[i+1:]...)
+				break
+			}
+		}
+		xelem.Attribs = append(xelem.Attribs, a)
+	}
+	xelem.Attribs = append(xelem.Attribs, own.Attribs...)
+	return nil
+}
+
+// attrName returns the name of an attribute as it appears in documents, in
+// the namespace ns of its declaration only if it is qualified.
+func attrName(ns string, qualified bool, name string) xml.Name {
+	if qualified {
+		return xml.Name{Space: ns, Local: name}
+	}
+	return xml.Name{Local: name}
+}
+
+// buildFromBaseAttributes adds the attributes of the complex type t to xelem,
+// including those t derives from its own base type.
+func (b *builder) buildFromBaseAttributes(xelem *xmlTree, t xsdComplexType) error {
+	var base string
+	var e *xsdExtension
+	if c := t.ComplexContent; c != nil {
+		if c.Restriction != nil {
+			return b.buildFromRestrictedAttributes(xelem, c.Restriction)
+		}
+		e = c.Extension
+	}
+	if c := t.SimpleContent; c != nil {
+		if c.Restriction != nil {
+			base = c.Restriction.Base
+		}
+		e = c.Extension
+	}
+	if e != nil {
+		base = e.Base
+	}
+
+	if bt, ok := b.findType(base).(xsdComplexType); ok {
+		if err := b.buildFromBaseAttributes(xelem, bt); err != nil {
+			return err
+		}
+	}
+	if e != nil {
+		if err := b.buildFromAttributes(xelem, e.Attributes); err != nil {
+			return err
+		}
+		if err := b.buildFromAttributeGroups(xelem, e.AttributeGroups); err != nil {
+			return err
+		}
 	}
+
+	if err := b.buildFromAttributes(xelem, t.Attributes); err != nil {
+		return err
+	}
+	return b.buildFromAttributeGroups(xelem, t.AttributeGroups)
 }
 
 // A simple content can refer to a text-only complex type
-func (b *builder) buildFromSimpleContent(xelem *xmlTree, c xsdSimpleContent) {
+func (b *builder) buildFromSimpleContent(xelem *xmlTree, c xsdSimpleContent) error {
 	if c.Extension != nil {
-		b.buildFromExtension(xelem, c.Extension)
+		if err := b.buildFromExtension(xelem, c.Extension); err != nil {
+			return err
+		}
 	}
 
 	if c.Restriction != nil {
-		b.buildFromRestriction(xelem, c.Restriction)
+		return b.buildFromRestriction(xelem, c.Restriction)
 	}
+
+	return nil
 }
 
 // buildFromExtension extends an existing type, simple or complex, with a
-// sequence.
-func (b *builder) buildFromExtension(xelem *xmlTree, e *xsdExtension) {
+// sequence. The character data of an element of simple content is of the
+// simple type, or built-in data type, it is based on. With embedBase set, a
+// complex type of complex content is embedded as a struct of its own, rather
+// than having its particles and attributes copied into xelem; being embedded
+// first, they still come first in the document.
+func (b *builder) buildFromExtension(xelem *xmlTree, e *xsdExtension) error {
 	switch t := b.findType(e.Base).(type) {
 	case xsdComplexType:
-		b.buildFromComplexType(xelem, t)
+		if b.embedBase && t.SimpleContent == nil {
+			base := &xmlTree{Name: t.Name, Namespace: xelem.Namespace}
+			if _, err := b.buildFromElementType(base, t); err != nil {
+				return err
+			}
+			xelem.Embed = base
+		} else if err := b.buildFromComplexType(xelem, t); err != nil {
+			return err
+		}
 	case xsdSimpleType:
-		b.buildFromSimpleType(xelem, t)
-		// If element is of simpleType and has attributes, it must collect
-		// its value as chardata.
-		if e.Attributes != nil {
-			xelem.Cdata = true
+		typ, st, err := b.simpleType(t, xelem.Name)
+		if err != nil {
+			return err
 		}
+		xelem.CdataType, xelem.SimpleType = typ, st
+	case unresolvedType:
+		return buildErrorf(e.xsdPos, fmt.Sprintf("extension of %q", e.Base), "no such base type")
 	default:
-		xelem.Type = t.(string)
-		// If element is of built-in type but has attributes, it must collect
-		// its value as chardata.
-		if e.Attributes != nil {
-			xelem.Cdata = true
+		if t != "anyType" {
+			xelem.CdataType = t.(string)
 		}
 	}
 
-	if e.Sequence != nil {
-		for _, e := range e.Sequence {
-			xelem.Children = append(xelem.Children, b.buildFromElement(e))
+	if g := e.modelGroup(); g != nil {
+		if err := b.buildFromModelGroup(xelem, *g); err != nil {
+			return err
 		}
 	}
 
 	if e.Attributes != nil {
-		b.buildFromAttributes(xelem, e.Attributes)
+		if err := b.buildFromAttributes(xelem, e.Attributes); err != nil {
+			return err
+		}
+	}
+
+	if e.AttributeGroups != nil {
+		return b.buildFromAttributeGroups(xelem, e.AttributeGroups)
 	}
+
+	return nil
+}
+
+// buildFromModelGroup adds the particles of a sequence, choice or all as
+// children to xelem. A sequence or an all is flattened into its parent, while
+// a choice becomes a child of its own, holding the alternatives. A choice
+// nested inside a choice is flattened, so that each of its alternatives
+// becomes an alternative of the enclosing choice, while a sequence nested
+// inside a choice is an alternative of its own. Any groups nested inside such
+// a sequence are flattened into it.
+func (b *builder) buildFromModelGroup(xelem *xmlTree, g xsdModelGroup) error {
+	if g.Kind != "choice" && xelem.Choice {
+		return b.buildFromSequence(xelem, g)
+	}
+	if g.Kind == "choice" && !xelem.Choice && !xelem.Sequence {
+		name := b.typeName(g.xsdPos, xelem.Type+"Choice")
+		choice := &xmlTree{
+			Name:      name,
+			Type:      name,
+			Namespace: xelem.Namespace,
+			List:      g.isList(),
+			Choice:    true,
+		}
+		if choice.List {
+			choice.MinOccurs, choice.MaxOccurs = listOccurs(g.Min, g.Max)
+		}
+		if err := b.buildFromParticles(choice, g.Particles); err != nil {
+			return err
+		}
+
+		// An alternative need not occur when another one is chosen, and
+		// may occur as many times as the choice is repeated.
+		for _, a := range choice.Children {
+			a.MinOccurs = 0
+			if choice.List {
+				a.MaxOccurs = repeatOccurs(a, choice.MaxOccurs)
+			}
+		}
+		xelem.Children = append(xelem.Children, choice)
+		return nil
+	}
+
+	first := len(xelem.Children)
+	if err := b.buildFromParticles(xelem, g.Particles); err != nil {
+		return err
+	}
+
+	// Elements in a repeated group may occur as many times as the group is
+	// repeated. How many of them must occur is left unchecked. Elements in
+	// an optional group, or in a choice flattened into a sequence, are
+	// optional themselves.
+	min, max := listOccurs(g.Min, g.Max)
+	choice := g.Kind == "choice"
+	for _, c := range xelem.Children[first:] {
+		switch {
+		case g.isList():
+			c.MaxOccurs = repeatOccurs(c, max)
+			c.MinOccurs = 0
+			c.List, c.Optional = true, false
+		case choice && c.List:
+			c.MinOccurs = 0
+		case (min == 0 || choice) && !c.List && !c.Choice:
+			c.Optional = true
+		}
+	}
+	return nil
+}
+
+// buildFromSequence adds the sequence or all g, nested inside the choice,
+// as an alternative of the choice holding the elements of g. A sequence of a
+// single element is simply that element.
+func (b *builder) buildFromSequence(choice *xmlTree, g xsdModelGroup) error {
+	seq := &xmlTree{
+		Namespace: choice.Namespace,
+		List:      choice.List,
+		Sequence:  true,
+	}
+	if err := b.buildFromModelGroup(seq, g); err != nil {
+		return err
+	}
+
+	switch len(seq.Children) {
+	case 0:
+	case 1:
+		choice.Children = append(choice.Children, seq.Children[0])
+	default:
+		seq.Name = b.typeName(g.xsdPos, choice.Type+"Sequence")
+		seq.Type = seq.Name
+		choice.Children = append(choice.Children, seq)
+	}
+	return nil
+}
+
+// listOccurs returns the occurrence bounds of a list, given by the minOccurs
+// and maxOccurs attributes min and max, with max zero if unbounded.
+func listOccurs(min, max string) (int, int) {
+	lo, hi := occurs(min, max)
+	if hi < 0 {
+		hi = 0
+	}
+	return lo, hi
 }
 
-func (b *builder) buildFromRestriction(xelem *xmlTree, r *xsdRestriction) {
+// repeatOccurs returns the maximum number of occurrences of e, when repeated
+// at most max times, or zero if unbounded.
+func repeatOccurs(e *xmlTree, max int) int {
+	if !e.List {
+		return max
+	}
+	if e.MaxOccurs == 0 || max == 0 {
+		return 0
+	}
+	return e.MaxOccurs * max
+}
+
+func (b *builder) buildFromParticles(xelem *xmlTree, ps []xsdParticle) error {
+	for _, p := range ps {
+		switch {
+		case p.Element != nil:
+			child, err := b.buildFromElement(*p.Element)
+			if err != nil {
+				return err
+			}
+			xelem.Children = append(xelem.Children, child)
+		case p.Group != nil:
+			if err := b.buildFromModelGroup(xelem, *p.Group); err != nil {
+				return err
+			}
+		case p.GroupRef != nil:
+			g, err := b.findGroup(*p.GroupRef)
+			if err != nil {
+				return err
+			}
+			if g == nil {
+				continue
+			}
+			name := splitQName(p.GroupRef.Ref)
+			if cycle := b.cycle(name); cycle != "" {
+				return buildErrorf(p.GroupRef.xsdPos, fmt.Sprintf("group reference %q", p.GroupRef.Ref),
+					"circular group definition %s", cycle)
+			}
+			b.expanding = append(b.expanding, name)
+			err = b.buildFromModelGroup(xelem, *g)
+			b.expanding = b.expanding[:len(b.expanding)-1]
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cycle returns the groups leading back to the group name, joined by arrows,
+// if name is being expanded already.
+func (b *builder) cycle(name xml.Name) string {
+	for i, n := range b.expanding {
+		if n == name {
+			var cycle []string
+			for _, n := range b.expanding[i:] {
+				cycle = append(cycle, n.Local)
+			}
+			return strings.Join(append(cycle, name.Local), " -> ")
+		}
+	}
+	return ""
+}
+
+// buildFromRestriction restricts the simple content of an existing type.
+func (b *builder) buildFromRestriction(xelem *xmlTree, r *xsdRestriction) error {
 	switch t := b.findType(r.Base).(type) {
 	case xsdSimpleType:
-		b.buildFromSimpleType(xelem, t)
+		typ, st, err := b.simpleType(t, xelem.Name)
+		if err != nil {
+			return err
+		}
+		xelem.CdataType, xelem.SimpleType = typ, st
+		return nil
 	case xsdComplexType:
-		b.buildFromComplexType(xelem, t)
-	case xsdComplexContent:
-		panic("Restriction on complex content is not implemented")
+		return b.buildFromComplexType(xelem, t)
+	case unresolvedType:
+		return buildErrorf(r.xsdPos, fmt.Sprintf("restriction of %q", r.Base), "no such base type")
 	default:
-		panic("Unexpected base type to restriction")
+		xelem.CdataType = t.(string)
+		return nil
 	}
 }
 
-func (b *builder) buildFromAttributes(xelem *xmlTree, attrs []xsdAttribute) {
+func (b *builder) buildFromAttributes(xelem *xmlTree, attrs []xsdAttribute) error {
 	for _, a := range attrs {
-		attr := xmlAttrib{Name: a.Name}
+		if a.Ref != "" {
+			var err error
+			if a, err = b.findAttribute(a); err != nil {
+				return err
+			}
+		}
+		attr := xmlAttrib{
+			Name:      a.Name,
+			Namespace: a.Namespace,
+			Qualified: a.Qualified,
+			Required:  a.Use == "required",
+		}
+		if a.Type == "" && a.SimpleType != nil {
+			typ, st, err := b.simpleType(*a.SimpleType, a.Name)
+			if err != nil {
+				return err
+			}
+			attr.Type, attr.SimpleType = typ, st
+		}
 		switch t := b.findType(a.Type).(type) {
 		case xsdSimpleType:
-			// Get type name from simpleType
-			// If Restriction.Base is a simpleType or complexType, we panic
-			attr.Type = b.findType(t.Restriction.Base).(string)
+			typ, st, err := b.simpleType(t, a.Name)
+			if err != nil {
+				return err
+			}
+			attr.Type, attr.SimpleType = typ, st
+		case xsdComplexType:
+			return buildErrorf(a.xsdPos, fmt.Sprintf("attribute %q", a.Name),
+				"type %q is a complex type", a.Type)
+		case unresolvedType:
+			return buildErrorf(a.xsdPos, fmt.Sprintf("attribute %q", a.Name),
+				"no such type %q", a.Type)
 		case string:
-			// If empty, then simpleType is present as content, but we ignore
-			// that now
-			attr.Type = t
+			if a.Type != "" {
+				attr.Type = t
+			}
+		}
+		if attr.Type == "" {
+			// An attribute without a type takes any simple value.
+			attr.Type = "string"
 		}
 		xelem.Attribs = append(xelem.Attribs, attr)
 	}
+	return nil
+}
+
+// findElement resolves an element reference to the global element it refers
+// to. The occurrence constraints of the reference apply to the resolved
+// element.
+func (b *builder) findElement(ref xsdElement) (xsdElement, error) {
+	e, ok := b.elements[splitQName(ref.Ref)]
+	if !ok {
+		return e, buildErrorf(ref.xsdPos, fmt.Sprintf("element reference %q", ref.Ref),
+			"no such global element")
+	}
+	e.Min, e.Max = ref.Min, ref.Max
+	return e, nil
+}
+
+// findAttribute resolves an attribute reference to the global attribute it
+// refers to. The use of the reference applies to the resolved attribute.
+func (b *builder) findAttribute(ref xsdAttribute) (xsdAttribute, error) {
+	a, ok := b.attributes[splitQName(ref.Ref)]
+	if !ok {
+		return a, buildErrorf(ref.xsdPos, fmt.Sprintf("attribute reference %q", ref.Ref),
+			"no such global attribute")
+	}
+	a.Use = ref.Use
+	return a, nil
+}
+
+// findGroup resolves a group reference to the model group of the named group
+// it refers to, with the occurrence constraints of the reference applied. A
+// group without a model group results in nil.
+func (b *builder) findGroup(ref xsdGroup) (*xsdModelGroup, error) {
+	g, ok := b.groups[splitQName(ref.Ref)]
+	if !ok {
+		return nil, buildErrorf(ref.xsdPos, fmt.Sprintf("group reference %q", ref.Ref),
+			"no such group")
+	}
+	mg := g.modelGroup()
+	if mg == nil {
+		return nil, nil
+	}
+	res := *mg
+	res.Min, res.Max = ref.Min, ref.Max
+	return &res, nil
+}
+
+// buildFromAttributeGroups expands attribute group references into the
+// attributes of xelem, including those of nested attribute groups.
+func (b *builder) buildFromAttributeGroups(xelem *xmlTree, refs []xsdAttributeGroup) error {
+	for _, r := range refs {
+		g, ok := b.attrGroups[splitQName(r.Ref)]
+		if !ok {
+			return buildErrorf(r.xsdPos, fmt.Sprintf("attributeGroup reference %q", r.Ref),
+				"no such attribute group")
+		}
+		name := splitQName(r.Ref)
+		if cycle := b.cycle(name); cycle != "" {
+			return buildErrorf(r.xsdPos, fmt.Sprintf("attributeGroup reference %q", r.Ref),
+				"circular attribute group definition %s", cycle)
+		}
+		if err := b.buildFromAttributes(xelem, g.Attributes); err != nil {
+			return err
+		}
+		b.expanding = append(b.expanding, name)
+		err := b.buildFromAttributeGroups(xelem, g.AttributeGroups)
+		b.expanding = b.expanding[:len(b.expanding)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // findType takes a type name and checks if it is a registered XSD type
 // (simple or complex), in which case that type is returned. If no such
 // type can be found, the XSD specific primitive types are mapped to their
-// Go correspondents. If no XSD type was found, the type name itself is
-// returned.
+// Go correspondents. A name that refers to no type at all results in an
+// unresolvedType, apart from anyType, and the empty name, which are returned
+// as they are.
 func (b *builder) findType(name string) interface{} {
-	name = stripNamespace(name)
-	if t, ok := b.complTypes[name]; ok {
+	qn := splitQName(name)
+	if t, ok := b.complTypes[qn]; ok {
 		return t
 	}
-	if t, ok := b.simplTypes[name]; ok {
+	if t, ok := b.simplTypes[qn]; ok {
 		return t
 	}
 
-	switch name {
-	case "boolean":
-		return "bool"
-	case "language", "Name", "token", "duration", "anyURI":
-		return "string"
-	case "long", "short", "integer", "int":
-		return "int"
-	case "unsignedShort":
-		return "uint16"
-	case "decimal":
-		return "float64"
-	case "dateTime":
-		return "time.Time"
-	default:
-		return name
+	if qn.Space == xsdNamespace {
+		if t, ok := bigTypes[qn.Local]; ok && b.bigNumbers {
+			return t
+		}
+		if t, ok := builtinTypes[qn.Local]; ok {
+			return t
+		}
+		if item, ok := builtinListTypes[qn.Local]; ok {
+			return xsdSimpleType{
+				xsdPos: xsdPos{File: xsdNamespace + "#" + qn.Local, TargetNamespace: xsdNamespace},
+				Name:   strings.ToLower(qn.Local),
+				List:   &xsdList{ItemType: "{" + xsdNamespace + "}" + item},
+			}
+		}
+		if qn.Local == "anyType" {
+			return qn.Local
+		}
+	}
+	if name == "" {
+		return ""
+	}
+
+	// A schema without a target namespace, declaring XSD as its default
+	// namespace, can only refer to its own types by mistake. It is common
+	// enough to be allowed for.
+	if qn.Space == xsdNamespace {
+		noNs := xml.Name{Local: qn.Local}
+		if t, ok := b.complTypes[noNs]; ok {
+			return t
+		}
+		if t, ok := b.simplTypes[noNs]; ok {
+			return t
+		}
+	}
+	return unresolvedType(name)
+}
+
+// unresolvedType is the name of a type reference that could not be resolved.
+type unresolvedType string
+
+// isBuiltinType reports whether local is the name of a built-in type of XSD.
+func isBuiltinType(local string) bool {
+	_, ok := builtinTypes[local]
+	_, list := builtinListTypes[local]
+	return ok || list || local == "anyType"
+}
+
+// builtinTypes maps the built-in data types of XSD to the Go types of their
+// values. The date and time types, and the binary types, map to the types of
+// the xsdtype package.
+var builtinTypes = map[string]string{
+	"anySimpleType":      "string",
+	"anyAtomicType":      "string",
+	"string":             "string",
+	"normalizedString":   "string",
+	"token":              "string",
+	"language":           "string",
+	"Name":               "string",
+	"NCName":             "string",
+	"NMTOKEN":            "string",
+	"ID":                 "string",
+	"IDREF":              "string",
+	"ENTITY":             "string",
+	"QName":              "string",
+	"NOTATION":           "string",
+	"anyURI":             "string",
+	"boolean":            "bool",
+	"decimal":            "float64",
+	"float":              "float32",
+	"double":             "float64",
+	"integer":            "int",
+	"nonPositiveInteger": "int",
+	"negativeInteger":    "int",
+	"long":               "int64",
+	"int":                "int",
+	"short":              "int16",
+	"byte":               "int8",
+	"nonNegativeInteger": "uint",
+	"positiveInteger":    "uint",
+	"unsignedLong":       "uint64",
+	"unsignedInt":        "uint32",
+	"unsignedShort":      "uint16",
+	"unsignedByte":       "uint8",
+	"dateTime":           "xsdtype.DateTime",
+	"dateTimeStamp":      "xsdtype.DateTime",
+	"date":               "xsdtype.Date",
+	"time":               "xsdtype.Time",
+	"gYearMonth":         "xsdtype.GYearMonth",
+	"gYear":              "xsdtype.GYear",
+	"gMonthDay":          "xsdtype.GMonthDay",
+	"gDay":               "xsdtype.GDay",
+	"gMonth":             "xsdtype.GMonth",
+	"duration":           "xsdtype.Duration",
+	"yearMonthDuration":  "xsdtype.Duration",
+	"dayTimeDuration":    "xsdtype.Duration",
+	"base64Binary":       "xsdtype.Base64Binary",
+	"hexBinary":          "xsdtype.HexBinary",
+}
+
+// builtinListTypes maps the built-in list types of XSD to their item types.
+// They are generated as list types of their own, of slices of strings.
+var builtinListTypes = map[string]string{
+	"NMTOKENS": "NMTOKEN",
+	"IDREFS":   "IDREF",
+	"ENTITIES": "ENTITY",
+}
+
+// bigTypes maps the built-in data types of unbounded size or precision to the
+// types of the xsdtype package holding their values exactly, in place of the
+// Go types of builtinTypes, when asked for.
+var bigTypes = map[string]string{
+	"decimal":            "xsdtype.Decimal",
+	"integer":            "xsdtype.Integer",
+	"nonPositiveInteger": "xsdtype.Integer",
+	"negativeInteger":    "xsdtype.Integer",
+	"nonNegativeInteger": "xsdtype.Integer",
+	"positiveInteger":    "xsdtype.Integer",
+}
+
+// splitQName splits a QName reference, as written by xsdSchema.qualify, into
+// its namespace and local name. An unresolved prefix is dropped.
+func splitQName(name string) xml.Name {
+	if strings.HasPrefix(name, "{") {
+		if i := strings.Index(name, "}"); i > 0 {
+			return xml.Name{Space: name[1:i], Local: name[i+1:]}
+		}
 	}
+	return xml.Name{Local: stripNamespace(name)}
 }
 
 func stripNamespace(name string) string {
-	if s := strings.Split(name, ":"); len(s) > 1 {
-		return s[len(s)-1]
+	if i := strings.LastIndexAny(name, ":}"); i >= 0 {
+		return name[i+1:]
 	}
 	return name
 }
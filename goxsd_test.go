@@ -2,7 +2,12 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -62,28 +67,43 @@ var (
 			xml: xmlTree{
 				Name: "titleList",
 				Type: "titleList",
-				Children: []*xmlTree{
-					&xmlTree{
-						Name:  "title",
-						Type:  "string",
-						Cdata: true,
-						List:  true,
-						Attribs: []xmlAttrib{
-							{Name: "language", Type: "string"},
-							{Name: "original", Type: "bool"},
+				Embed: &xmlTree{
+					Name:  "titleList",
+					Type:  "titleListType",
+					Named: true,
+					Children: []*xmlTree{
+						&xmlTree{
+							Name:      "title",
+							Type:      "originalTitleType",
+							Named:     true,
+							Cdata:     true,
+							CdataType: "string",
+							List:      true,
+							MinOccurs: 1,
+							Attribs: []xmlAttrib{
+								{Name: "language", Type: "string"},
+								{Name: "original", Type: "bool"},
+							},
 						},
 					},
 				},
 			},
 			gosrc: `
+import "encoding/xml"
+
 type titleList struct {
-	Title []title ` + "`xml:\"title\"`" + `
+	XMLName xml.Name ` + "`xml:\"titleList\"`" + `
+	titleListType
 }
 
-type title struct {
+type titleListType struct {
+	Title []originalTitleType ` + "`xml:\"title\"`" + `
+}
+
+type originalTitleType struct {
 	Language string ` + "`xml:\"language,attr\"`" + `
 	Original bool ` + "`xml:\"original,attr\"`" + `
-	Title    string ` + "`xml:\",chardata\"`" + `
+	Value    string ` + "`xml:\",chardata\"`" + `
 }
 
 				`,
@@ -122,24 +142,35 @@ type title struct {
 				Type: "tagList",
 				Children: []*xmlTree{
 					&xmlTree{
-						Name:  "tag",
-						Type:  "string",
-						List:  true,
-						Cdata: true,
+						Name:      "tag",
+						Type:      "tagReferenceType",
+						Named:     true,
+						List:      true,
+						Cdata:     true,
+						CdataType: "string",
 						Attribs: []xmlAttrib{
-							{Name: "type", Type: "string"},
+							{Name: "type", Type: "string", Required: true},
+						},
+						SimpleType: &xmlSimpleType{
+							Name:   "nidType",
+							Base:   "string",
+							Facets: []xmlFacets{{Patterns: []string{`[0-9a-zA-Z\-]+`}}},
+							Flat:   true,
 						},
 					},
 				},
 			},
 			gosrc: `
+import "encoding/xml"
+
 type tagList struct {
-	Tag []tag ` + "`xml:\"tag\"`" + `
+	XMLName xml.Name ` + "`xml:\"tagList\"`" + `
+	Tag []tagReferenceType ` + "`xml:\"tag\"`" + `
 }
 
-type tag struct {
+type tagReferenceType struct {
 	Type string ` + "`xml:\"type,attr\"`" + `
-	Tag string ` + "`xml:\",chardata\"`" + `
+	Value string ` + "`xml:\",chardata\"`" + `
 }
 			`,
 		},
@@ -158,18 +189,30 @@ type tag struct {
 	</complexType>
 </schema>`,
 			xml: xmlTree{
-				Name:  "tagId",
-				Type:  "string",
-				List:  false,
-				Cdata: true,
-				Attribs: []xmlAttrib{
-					{Name: "type", Type: "string"},
+				Name: "tagId",
+				Type: "tagId",
+				Embed: &xmlTree{
+					Name:      "tagId",
+					Type:      "tagReferenceType",
+					Named:     true,
+					Cdata:     true,
+					CdataType: "string",
+					Attribs: []xmlAttrib{
+						{Name: "type", Type: "string", Required: true},
+					},
 				},
 			},
 			gosrc: `
+import "encoding/xml"
+
 type tagID struct {
+	XMLName xml.Name ` + "`xml:\"tagId\"`" + `
+	tagReferenceType
+}
+
+type tagReferenceType struct {
 	Type string ` + "`xml:\"type,attr\"`" + `
-	TagID string ` + "`xml:\",chardata\"`" + `
+	Value string ` + "`xml:\",chardata\"`" + `
 }
 			`,
 		},
@@ -188,18 +231,30 @@ type tagID struct {
 	</complexType>
 </schema>`,
 			xml: xmlTree{
-				Name:  "url",
-				Type:  "string",
-				List:  false,
-				Cdata: true,
-				Attribs: []xmlAttrib{
-					{Name: "type", Type: "string"},
+				Name: "url",
+				Type: "url",
+				Embed: &xmlTree{
+					Name:      "url",
+					Type:      "tagReferenceType",
+					Named:     true,
+					Cdata:     true,
+					CdataType: "string",
+					Attribs: []xmlAttrib{
+						{Name: "type", Type: "string", Required: true},
+					},
 				},
 			},
 			gosrc: `
+import "encoding/xml"
+
 type XxxURL struct {
+	XMLName xml.Name ` + "`xml:\"url\"`" + `
+	XxxTagReferenceType
+}
+
+type XxxTagReferenceType struct {
 	Type string ` + "`xml:\"type,attr\"`" + `
-	URL string ` + "`xml:\",chardata\"`" + `
+	Value string ` + "`xml:\",chardata\"`" + `
 }
 			`,
 		},
@@ -214,9 +269,22 @@ type XxxURL struct {
 			xml: xmlTree{
 				Name: "empty",
 				Type: "empty",
+				Embed: &xmlTree{
+					Name:  "empty",
+					Type:  "tagReferenceType",
+					Named: true,
+				},
 			},
 			gosrc: `
-type empty struct {}
+import "encoding/xml"
+
+type empty struct {
+	XMLName xml.Name ` + "`xml:\"empty\"`" + `
+	tagReferenceType
+}
+
+type tagReferenceType struct {
+}
 			`,
 		},
 	}
@@ -240,7 +308,10 @@ func TestBuildXmlElem(t *testing.T) {
 		}
 
 		bldr := newBuilder(schemas)
-		elems := bldr.buildXML()
+		elems, err := bldr.buildXML()
+		if err != nil {
+			t.Fatal(err)
+		}
 		if len(elems) != 1 {
 			t.Errorf("wrong number of xml elements")
 		}
@@ -321,3 +392,2925 @@ func TestReplace(t *testing.T) {
 		i++
 	}
 }
+
+// generateFromXSD parses and builds the given XSD, and returns the Go source
+// generated by g, stripped of comments and whitespace.
+func generateFromXSD(t *testing.T, xsd string, g generator) string {
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := g.do(&out, buildXML(t, schemas)); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	return strings.Join(strings.Fields(out.String()), "")
+}
+
+func TestDocComments(t *testing.T) {
+	schemas, err := parse(strings.NewReader(`<schema>
+	<element name="order" type="orderType"/>
+	<complexType name="orderType">
+		<attribute name="id" type="string"/>
+	</complexType>
+</schema>`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, buildXML(t, schemas)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// order is generated from an XSD element\n",
+		"// orderType is generated from an XSD complex type\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing doc comment %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestChoice(t *testing.T) {
+	xsd := `<schema>
+	<element name="shape">
+		<complexType>
+			<sequence>
+				<element name="id" type="string"/>
+				<choice>
+					<element name="circle" type="decimal"/>
+					<sequence>
+						<element name="width" type="int"/>
+						<element name="height" type="int"/>
+					</sequence>
+				</choice>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xmlTree{
+		Name: "shape",
+		Type: "shape",
+		Children: []*xmlTree{
+			{Name: "id", Type: "string"},
+			{
+				Name:   "shapeChoice",
+				Type:   "shapeChoice",
+				Choice: true,
+				Children: []*xmlTree{
+					{Name: "circle", Type: "float64"},
+					{
+						Name:     "shapeChoiceSequence",
+						Type:     "shapeChoiceSequence",
+						Sequence: true,
+						Children: []*xmlTree{
+							{Name: "width", Type: "int"},
+							{Name: "height", Type: "int"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+
+	for _, tst := range []struct {
+		choiceIface bool
+		gosrc       string
+	}{
+		{
+			choiceIface: false,
+			gosrc: `
+import "encoding/xml"
+
+type shape struct {
+	XMLName xml.Name ` + "`xml:\"shape\"`" + `
+	ID     string   ` + "`xml:\"id\"`" + `
+	Circle *float64 ` + "`xml:\"circle\"`" + `
+	shapeChoiceSequence
+}
+
+type shapeChoiceSequence struct {
+	Width  *int ` + "`xml:\"width\"`" + `
+	Height *int ` + "`xml:\"height\"`" + `
+}
+			`,
+		},
+		{
+			choiceIface: true,
+			gosrc: `
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/ivarg/goxsd/xsdtype"
+)
+
+type shape struct {
+	XMLName     xml.Name     ` + "`xml:\"shape\"`" + `
+	ID          string       ` + "`xml:\"id\"`" + `
+	ShapeChoice *shapeChoice ` + "`xml:\",any\"`" + `
+}
+
+func (v *shape) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Fields shape
+	var x struct {
+		*Fields
+	}
+	x.Fields = (*Fields)(v)
+	return xsdtype.DecodeElement(d, start, &x, func(d *xml.Decoder, child xml.StartElement) (bool, error) {
+		switch child.Name.Local {
+		case "circle":
+			return true, d.DecodeElement(&v.ShapeChoice, &child)
+		case "width":
+			if v.ShapeChoice == nil {
+				v.ShapeChoice = new(shapeChoice)
+			}
+			s, _ := v.ShapeChoice.Value.(shapeChoiceSequence)
+			err := d.DecodeElement(&s.Width, &child)
+			v.ShapeChoice.Value = s
+			return true, err
+		case "height":
+			if v.ShapeChoice == nil {
+				v.ShapeChoice = new(shapeChoice)
+			}
+			s, _ := v.ShapeChoice.Value.(shapeChoiceSequence)
+			err := d.DecodeElement(&s.Height, &child)
+			v.ShapeChoice.Value = s
+			return true, err
+		case "id":
+			return false, nil
+		}
+		return true, fmt.Errorf("unexpected element %s in element %s", child.Name.Local, start.Name.Local)
+	})
+}
+
+type shapeChoice struct {
+	Value shapeChoiceValue
+}
+
+type shapeChoiceValue interface {
+	isShapeChoice()
+}
+
+type shapeChoiceCircle struct {
+	Value float64 ` + "`xml:\",chardata\"`" + `
+}
+
+func (shapeChoiceCircle) isShapeChoice() {}
+
+func (shapeChoiceSequence) isShapeChoice() {}
+
+func (c *shapeChoice) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "circle":
+		var v shapeChoiceCircle
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		c.Value = v
+		return nil
+	}
+	return fmt.Errorf("unexpected element %s, not an alternative of shapeChoice", start.Name.Local)
+}
+
+func (c shapeChoice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch v := c.Value.(type) {
+	case shapeChoiceCircle:
+		start.Name = xml.Name{Local: "circle"}
+		return e.EncodeElement(v, start)
+	case shapeChoiceSequence:
+		if err := e.EncodeElement(v.Width, xml.StartElement{Name: xml.Name{Local: "width"}}); err != nil {
+			return err
+		}
+		if err := e.EncodeElement(v.Height, xml.StartElement{Name: xml.Name{Local: "height"}}); err != nil {
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
+type shapeChoiceSequence struct {
+	Width  int ` + "`xml:\"width\"`" + `
+	Height int ` + "`xml:\"height\"`" + `
+}
+			`,
+		},
+	} {
+		got := generateFromXSD(t, xsd, generator{choiceIface: tst.choiceIface})
+		if want := strings.Join(strings.Fields(tst.gosrc), ""); got != want {
+			t.Errorf("Unexpected generated Go source, choiceIface: %v", tst.choiceIface)
+			t.Log(got)
+			t.Log(want)
+		}
+	}
+}
+
+func TestSharedTypes(t *testing.T) {
+	// Each type has two elements of the next, so that building every use
+	// of a type again would take 2^depth builds.
+	const depth = 40
+	var xsd strings.Builder
+	xsd.WriteString(`<schema><element name="root" type="t0"/>`)
+	for i := 0; i < depth; i++ {
+		fmt.Fprintf(&xsd, `<complexType name="t%d"><sequence>`, i)
+		if i < depth-1 {
+			fmt.Fprintf(&xsd, `<element name="a" type="t%d"/><element name="b" type="t%[1]d"/>`, i+1)
+		}
+		xsd.WriteString(`</sequence></complexType>`)
+	}
+	xsd.WriteString(`</schema>`)
+
+	schemas, err := parse(strings.NewReader(xsd.String()), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := buildXML(t, schemas)
+	e := roots[0].Embed
+	for i := 0; i < depth-1; i++ {
+		if len(e.Children) != 2 || e.Children[0].Type != e.Children[1].Type {
+			t.Fatalf("Unexpected elements of t%d", i)
+		}
+		if e.Children[0].Name != "a" || e.Children[1].Name != "b" {
+			t.Fatalf("Unexpected names of elements of t%d: %s, %s", i, e.Children[0].Name, e.Children[1].Name)
+		}
+		e = e.Children[1]
+	}
+	if e.Type != "t"+strconv.Itoa(depth-1) {
+		t.Errorf("Unexpected innermost type %s", e.Type)
+	}
+}
+
+func TestSimpleRoots(t *testing.T) {
+	xsd := `<schema>
+	<element name="note" type="string"/>
+	<element name="code" type="codeType"/>
+	<complexType name="codeType">
+		<simpleContent>
+			<extension base="int"/>
+		</simpleContent>
+	</complexType>
+</schema>`
+
+	gosrc := `
+import "encoding/xml"
+
+type note struct {
+	XMLName xml.Name ` + "`xml:\"note\"`" + `
+	Value   string   ` + "`xml:\",chardata\"`" + `
+}
+
+type code struct {
+	XMLName xml.Name ` + "`xml:\"code\"`" + `
+	Value   int      ` + "`xml:\",chardata\"`" + `
+}
+`
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+	}
+}
+
+func TestAll(t *testing.T) {
+	xsd := `<schema>
+	<element name="person" type="personType"/>
+	<complexType name="personType">
+		<all>
+			<element name="name" type="string"/>
+			<element name="age" type="int" minOccurs="0"/>
+		</all>
+		<attribute name="id" type="string"/>
+	</complexType>
+</schema>`
+
+	gosrc := `
+import "encoding/xml"
+
+type person struct {
+	XMLName xml.Name ` + "`xml:\"person\"`" + `
+	personType
+}
+
+type personType struct {
+	ID   string ` + "`xml:\"id,attr\"`" + `
+	Name string ` + "`xml:\"name\"`" + `
+	Age  int    ` + "`xml:\"age\"`" + `
+}
+	`
+
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+		t.Log(want)
+	}
+}
+
+func TestElementRef(t *testing.T) {
+	xsd := `<schema targetNamespace="urn:test" xmlns:tns="urn:test">
+	<element name="customer">
+		<complexType>
+			<sequence>
+				<element ref="tns:address" maxOccurs="unbounded"/>
+				<element ref="tns:note"/>
+			</sequence>
+		</complexType>
+	</element>
+	<element name="address" type="tns:addressType"/>
+	<element name="note" type="string"/>
+	<complexType name="addressType">
+		<sequence>
+			<element name="street" type="string"/>
+		</sequence>
+	</complexType>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xmlTree{
+		Name:      "customer",
+		Qualified: true,
+		Type:      "customer",
+		Namespace: "urn:test",
+		Children: []*xmlTree{
+			{
+				Name:      "address",
+				Type:      "addressType",
+				Named:     true,
+				Namespace: "urn:test",
+				Qualified: true,
+				List:      true,
+				MinOccurs: 1,
+				Children: []*xmlTree{
+					{Name: "street", Type: "string", Namespace: "urn:test"},
+				},
+			},
+			{Name: "note", Type: "string", Namespace: "urn:test", Qualified: true},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+}
+
+func TestGroupRef(t *testing.T) {
+	xsd := `<schema targetNamespace="urn:test" xmlns:tns="urn:test">
+	<element name="party" type="tns:partyType"/>
+	<complexType name="partyType">
+		<sequence>
+			<element name="name" type="string"/>
+			<group ref="tns:contactGroup" maxOccurs="unbounded"/>
+		</sequence>
+	</complexType>
+	<element name="organisation">
+		<complexType>
+			<group ref="tns:contactGroup"/>
+		</complexType>
+	</element>
+	<group name="contactGroup">
+		<choice>
+			<element name="phone" type="string"/>
+			<element name="email" type="string"/>
+		</choice>
+	</group>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*xmlTree{
+		{
+			Name:      "party",
+			Qualified: true,
+			Type:      "party",
+			Namespace: "urn:test",
+			Embed: &xmlTree{
+				Name:      "party",
+				Qualified: true,
+				Type:      "partyType",
+				Named:     true,
+				Namespace: "urn:test",
+				Children: []*xmlTree{
+					{Name: "name", Type: "string", Namespace: "urn:test"},
+					{
+						Name:      "partyTypeChoice",
+						Type:      "partyTypeChoice",
+						Namespace: "urn:test",
+						List:      true,
+						Choice:    true,
+						MinOccurs: 1,
+						Children: []*xmlTree{
+							{Name: "phone", Type: "string", Namespace: "urn:test"},
+							{Name: "email", Type: "string", Namespace: "urn:test"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "organisation",
+			Qualified: true,
+			Type:      "organisation",
+			Namespace: "urn:test",
+			Children: []*xmlTree{
+				{
+					Name:      "partyTypeChoice",
+					Type:      "partyTypeChoice",
+					Namespace: "urn:test",
+					Choice:    true,
+					Children: []*xmlTree{
+						{Name: "phone", Type: "string", Namespace: "urn:test"},
+						{Name: "email", Type: "string", Namespace: "urn:test"},
+					},
+				},
+			},
+		},
+	}
+	if elems := buildXML(t, schemas); !reflect.DeepEqual(want, elems) {
+		t.Errorf("Unexpected XML elements")
+		pretty.Println(want)
+		pretty.Println(elems)
+	}
+}
+
+func TestAttributeGroupRef(t *testing.T) {
+	xsd := `<schema targetNamespace="urn:test" xmlns:tns="urn:test">
+	<element name="link">
+		<complexType>
+			<simpleContent>
+				<extension base="string">
+					<attributeGroup ref="tns:linkAttrs"/>
+				</extension>
+			</simpleContent>
+		</complexType>
+	</element>
+	<attributeGroup name="linkAttrs">
+		<attribute name="href" type="anyURI"/>
+		<attributeGroup ref="tns:commonAttrs"/>
+	</attributeGroup>
+	<attributeGroup name="commonAttrs">
+		<attribute name="id" type="string"/>
+	</attributeGroup>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xmlTree{
+		Name:      "link",
+		Qualified: true,
+		Type:      "link",
+		Namespace: "urn:test",
+		Cdata:     true,
+		CdataType: "string",
+		Attribs: []xmlAttrib{
+			{Name: "href", Type: "string", Namespace: "urn:test"},
+			{Name: "id", Type: "string", Namespace: "urn:test"},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+}
+
+func TestAttributeRef(t *testing.T) {
+	xsd := `<schema targetNamespace="urn:test" xmlns:tns="urn:test">
+	<element name="note">
+		<complexType>
+			<simpleContent>
+				<extension base="string">
+					<attribute ref="tns:lang" use="required"/>
+					<attribute name="id" type="string"/>
+				</extension>
+			</simpleContent>
+		</complexType>
+	</element>
+	<attribute name="lang" type="language"/>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xmlTree{
+		Name:      "note",
+		Qualified: true,
+		Type:      "note",
+		Namespace: "urn:test",
+		Cdata:     true,
+		CdataType: "string",
+		Attribs: []xmlAttrib{
+			{Name: "lang", Type: "string", Namespace: "urn:test", Qualified: true, Required: true},
+			{Name: "id", Type: "string", Namespace: "urn:test"},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+}
+
+func TestIncludeRedefineOverride(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.xsd": `<schema>
+	<include schemaLocation="address.xsd"/>
+	<redefine schemaLocation="item.xsd">
+		<complexType name="itemType">
+			<complexContent>
+				<extension base="itemType">
+					<sequence>
+						<element name="price" type="decimal"/>
+					</sequence>
+				</extension>
+			</complexContent>
+		</complexType>
+	</redefine>
+	<override schemaLocation="status.xsd">
+		<simpleType name="statusType">
+			<restriction base="int"/>
+		</simpleType>
+	</override>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="address" type="tns:addressType"/>
+				<element name="item" type="itemType"/>
+				<element name="status" type="statusType"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+		"address.xsd": `<schema>
+	<complexType name="addressType">
+		<sequence>
+			<element name="street" type="string"/>
+		</sequence>
+	</complexType>
+</schema>`,
+		"item.xsd": `<schema>
+	<complexType name="itemType">
+		<sequence>
+			<element name="name" type="string"/>
+		</sequence>
+	</complexType>
+</schema>`,
+		"status.xsd": `<schema>
+	<simpleType name="statusType">
+		<restriction base="string"/>
+	</simpleType>
+</schema>`,
+	}
+	for name, xsd := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "order.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 {
+		t.Fatalf("Unexpected number of schemas: %d", len(schemas))
+	}
+	want := xmlTree{
+		Name: "order",
+		Type: "order",
+		Children: []*xmlTree{
+			{
+				Name:  "address",
+				Type:  "addressType",
+				Named: true,
+				Children: []*xmlTree{
+					{Name: "street", Type: "string"},
+				},
+			},
+			{
+				Name:  "item",
+				Type:  "itemType",
+				Named: true,
+				Children: []*xmlTree{
+					{Name: "name", Type: "string"},
+					{Name: "price", Type: "float64"},
+				},
+			},
+			{Name: "status", Type: "int"},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+}
+
+func TestRedefineLoaded(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.xsd": `<schema>
+	<include schemaLocation="common.xsd"/>
+	<redefine schemaLocation="item.xsd">
+		<complexType name="itemType">
+			<complexContent>
+				<extension base="itemType"/>
+			</complexContent>
+		</complexType>
+	</redefine>
+</schema>`,
+		"common.xsd": `<schema>
+	<include schemaLocation="item.xsd"/>
+</schema>`,
+		"item.xsd": `<schema>
+	<complexType name="itemType"/>
+</schema>`,
+	}
+	for name, xsd := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order := filepath.Join(dir, "order.xsd")
+	_, err := parseXSDFile(order)
+	want := fmt.Sprintf("%s: cannot redefine item.xsd, already loaded through %s", order, filepath.Join(dir, "common.xsd"))
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestSchemaLoader(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.xsd": `<schema targetNamespace="urn:main">
+	<import namespace="urn:a" schemaLocation="a/types.xsd"/>
+	<import namespace="urn:b" schemaLocation="b/types.xsd"/>
+	<element name="main" type="string"/>
+</schema>`,
+		"a/types.xsd": `<schema targetNamespace="urn:a">
+	<import namespace="urn:b" schemaLocation="../b/types.xsd"/>
+	<element name="a" type="string"/>
+</schema>`,
+		"b/types.xsd": `<schema targetNamespace="urn:b">
+	<import namespace="urn:a" schemaLocation="../a/types.xsd"/>
+	<element name="b" type="string"/>
+</schema>`,
+	}
+	for name, xsd := range files {
+		fname := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Parsing twice must yield the same schemas, as each run has a loader
+	// of its own.
+	for i := 0; i < 2; i++ {
+		schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range schemas {
+			got = append(got, s.TargetNamespace)
+		}
+		if want := []string{"urn:main", "urn:a", "urn:b"}; !reflect.DeepEqual(want, got) {
+			t.Errorf("[%d] Unexpected schemas: %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestChameleonInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.xsd": `<schema targetNamespace="urn:main" xmlns:m="urn:main">
+	<import namespace="urn:other" schemaLocation="other.xsd"/>
+	<include schemaLocation="common.xsd"/>
+	<element name="p" type="m:pointType"/>
+</schema>`,
+		"other.xsd": `<schema targetNamespace="urn:other" xmlns:o="urn:other">
+	<include schemaLocation="common.xsd"/>
+	<element name="q" type="o:pointType"/>
+</schema>`,
+		"common.xsd": `<schema elementFormDefault="qualified">
+	<complexType name="pointType">
+		<sequence>
+			<element name="x" type="int"/>
+		</sequence>
+	</complexType>
+</schema>`,
+	}
+	for name, xsd := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := buildXML(t, schemas)
+	if len(roots) != 2 {
+		t.Fatalf("Unexpected number of roots: %d", len(roots))
+	}
+	p, q := roots[0].Embed, roots[1].Embed
+	if p.Type == q.Type {
+		t.Errorf("pointType in both namespaces generated as %s", p.Type)
+	}
+	if p.Children[0].Namespace != "urn:main" || q.Children[0].Namespace != "urn:other" {
+		t.Errorf("Unexpected namespaces of x: %s and %s", p.Children[0].Namespace, q.Children[0].Namespace)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.xsd": `<schema targetNamespace="urn:main" xmlns:a="urn:a" xmlns:b="urn:b">
+	<import namespace="urn:a" schemaLocation="a.xsd"/>
+	<import namespace="urn:b" schemaLocation="b.xsd"/>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element ref="a:address"/>
+				<element name="shipTo" type="b:AddressType"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+		"a.xsd": `<schema targetNamespace="urn:a" xmlns="urn:a">
+	<element name="address" type="AddressType"/>
+	<complexType name="AddressType">
+		<sequence>
+			<element name="street" type="string"/>
+		</sequence>
+	</complexType>
+</schema>`,
+		"b.xsd": `<schema targetNamespace="urn:b" xmlns:b="urn:b"
+	elementFormDefault="qualified" attributeFormDefault="qualified">
+	<element name="address" type="b:AddressType"/>
+	<complexType name="AddressType">
+		<sequence>
+			<element name="city" type="string"/>
+		</sequence>
+		<attribute name="zip" type="string"/>
+	</complexType>
+</schema>`,
+	}
+	for name, xsd := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, buildXML(t, schemas)); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+
+	gosrc := `
+import (
+	"encoding/xml"
+
+	"github.com/ivarg/goxsd/xsdtype"
+)
+
+type order struct {
+	XMLName xml.Name     ` + "`xml:\"urn:main order\"`" + `
+	Address AddressType  ` + "`xml:\"urn:a address\"`" + `
+	ShipTo  AddressType2 ` + "`xml:\"shipTo\"`" + `
+}
+
+func (v order) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type Fields order
+	x := struct {
+		*Fields
+	}{Fields: (*Fields)(&v)}
+	start.Name = xml.Name{Space: "urn:main", Local: "order"}
+	return e.EncodeElement(x, xsdtype.PrefixName(start))
+}
+
+type AddressType struct {
+	Street string ` + "`xml:\"street\"`" + `
+}
+
+func (v AddressType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type Fields AddressType
+	x := struct {
+		*Fields
+	}{Fields: (*Fields)(&v)}
+	return e.EncodeElement(x, xsdtype.PrefixName(start))
+}
+
+type AddressType2 struct {
+	Zip  string ` + "`xml:\"urn:b zip,attr\"`" + `
+	City string ` + "`xml:\"urn:b city\"`" + `
+}
+
+type address struct {
+	XMLName xml.Name ` + "`xml:\"urn:a address\"`" + `
+	AddressType
+}
+
+func (v address) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type Fields address
+	x := struct {
+		*Fields
+		MarshalXML struct{} ` + "`xml:\"-\"`" + `
+	}{Fields: (*Fields)(&v)}
+	start.Name = xml.Name{Space: "urn:a", Local: "address"}
+	return e.EncodeElement(x, xsdtype.PrefixName(start))
+}
+
+type address2 struct {
+	XMLName xml.Name ` + "`xml:\"urn:b address\"`" + `
+	AddressType2
+}
+	`
+	got := strings.Join(strings.Fields(out.String()), "")
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(out.String())
+	}
+}
+
+// buildXML builds the xmlTree roots of a set of schemas, failing the test on
+// error.
+func buildXML(t *testing.T, schemas []xsdSchema) []*xmlTree {
+	t.Helper()
+	elems, err := newBuilder(schemas).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return elems
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		xsd string
+		err string
+	}{
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element ref="missing"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:5: element reference "missing": no such global element`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:attribute ref="missing"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:4: attribute reference "missing": no such global attribute`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:group ref="missing"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:4: group reference "missing": no such group`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:group name="g">
+    <xs:sequence>
+      <xs:group ref="h"/>
+    </xs:sequence>
+  </xs:group>
+  <xs:group name="h">
+    <xs:choice>
+      <xs:element name="b" type="xs:string"/>
+      <xs:group ref="g"/>
+    </xs:choice>
+  </xs:group>
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:group ref="g"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:10: group reference "g": circular group definition g -> h -> g`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:attributeGroup name="g">
+    <xs:attribute name="b" type="xs:string"/>
+    <xs:attributeGroup ref="h"/>
+  </xs:attributeGroup>
+  <xs:attributeGroup name="h">
+    <xs:attributeGroup ref="g"/>
+  </xs:attributeGroup>
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:attributeGroup ref="g"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:7: attributeGroup reference "g": circular attribute group definition g -> h -> g`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:attributeGroup ref="missing"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:4: attributeGroup reference "missing": no such attribute group`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:complexType name="t"/>
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:attribute name="b" type="t"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:5: attribute "b": type "t" is a complex type`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:complexType name="t"/>
+  <xs:element name="a">
+    <xs:simpleType>
+      <xs:restriction base="t"/>
+    </xs:simpleType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:4: anonymous simpleType: restriction base "t" is a complex type`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:t">
+  <xs:element name="a" type="t:Missing"/>
+</xs:schema>`,
+			err: `test.xsd:2: element "a": no such type "{urn:t}Missing"`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:t">
+  <xs:element name="a" type="t:decimal"/>
+</xs:schema>`,
+			err: `test.xsd:2: element "a": no such type "{urn:t}decimal"`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:attribute name="b" type="Missing"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:4: attribute "b": no such type "Missing"`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="a">
+    <xs:complexType>
+      <xs:complexContent>
+        <xs:extension base="Missing"/>
+      </xs:complexContent>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`,
+			err: `test.xsd:5: extension of "Missing": no such base type`,
+		},
+		{
+			xsd: `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="s">
+    <xs:list itemType="Missing"/>
+  </xs:simpleType>
+  <xs:element name="a" type="s"/>
+</xs:schema>`,
+			err: `test.xsd:2: list type "s": no such item type "Missing"`,
+		},
+	}
+
+	for _, tst := range tests {
+		schemas, err := parse(strings.NewReader(tst.xsd), "test.xsd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = newBuilder(schemas).buildXML()
+		if err == nil || err.Error() != tst.err {
+			t.Errorf("got error %v, want %s", err, tst.err)
+		}
+	}
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	xsd := `<schema>
+	<element name="section" type="sectionType"/>
+	<complexType name="sectionType">
+		<sequence>
+			<element name="title" type="string"/>
+			<element name="parent" type="sectionType" minOccurs="0"/>
+			<element name="section" type="sectionType" minOccurs="0" maxOccurs="unbounded"/>
+		</sequence>
+	</complexType>
+	<element name="node">
+		<complexType>
+			<sequence>
+				<element ref="node" minOccurs="0" maxOccurs="unbounded"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*xmlTree{
+		{
+			Name: "section",
+			Type: "section",
+			Embed: &xmlTree{
+				Name:  "section",
+				Type:  "sectionType",
+				Named: true,
+				Children: []*xmlTree{
+					{Name: "title", Type: "string"},
+					{Name: "parent", Type: "sectionType", Recursive: true, Optional: true},
+					{Name: "section", Type: "sectionType", List: true, Recursive: true},
+				},
+			},
+		},
+		{
+			Name: "node",
+			Type: "node",
+			Children: []*xmlTree{
+				{Name: "node", Type: "node", List: true, Recursive: true},
+			},
+		},
+	}
+	if elems := buildXML(t, schemas); !reflect.DeepEqual(want, elems) {
+		t.Errorf("Unexpected XML elements")
+		pretty.Println(want)
+		pretty.Println(elems)
+	}
+
+	gosrc := `
+import "encoding/xml"
+
+type section struct {
+	XMLName xml.Name ` + "`xml:\"section\"`" + `
+	sectionType
+}
+
+type sectionType struct {
+	Title   string        ` + "`xml:\"title\"`" + `
+	Parent  *sectionType  ` + "`xml:\"parent\"`" + `
+	Section []sectionType ` + "`xml:\"section\"`" + `
+}
+
+type node struct {
+	XMLName xml.Name ` + "`xml:\"node\"`" + `
+	Node    []node   ` + "`xml:\"node\"`" + `
+}
+`
+	if got := generateFromXSD(t, xsd, generator{}); got != strings.Join(strings.Fields(gosrc), "") {
+		t.Errorf("Unexpected generated Go source:\n%s", got)
+	}
+}
+
+func TestTypePerXSDType(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="billTo" type="addressType"/>
+				<element name="shipTo" type="addressType"/>
+				<element name="item">
+					<complexType>
+						<attribute name="sku" type="string"/>
+					</complexType>
+				</element>
+				<element name="bundle">
+					<complexType>
+						<sequence>
+							<element name="item" maxOccurs="unbounded">
+								<complexType>
+									<attribute name="ref" type="int"/>
+								</complexType>
+							</element>
+						</sequence>
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<complexType name="addressType">
+		<sequence>
+			<element name="street" type="string"/>
+		</sequence>
+	</complexType>
+</schema>`
+
+	gosrc := `
+import "encoding/xml"
+
+type order struct {
+	XMLName xml.Name    ` + "`xml:\"order\"`" + `
+	BillTo  addressType ` + "`xml:\"billTo\"`" + `
+	ShipTo  addressType ` + "`xml:\"shipTo\"`" + `
+	Item    item        ` + "`xml:\"item\"`" + `
+	Bundle  bundle      ` + "`xml:\"bundle\"`" + `
+}
+
+type addressType struct {
+	Street string ` + "`xml:\"street\"`" + `
+}
+
+type item struct {
+	Sku string ` + "`xml:\"sku,attr\"`" + `
+}
+
+type bundle struct {
+	Item []item2 ` + "`xml:\"item\"`" + `
+}
+
+type item2 struct {
+	Ref int ` + "`xml:\"ref,attr\"`" + `
+}
+`
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+		t.Log(want)
+	}
+}
+
+func TestEnumerations(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="status" type="statusType"/>
+				<element name="priority">
+					<simpleType>
+						<restriction base="int">
+							<enumeration value="1"/>
+							<enumeration value="2"/>
+						</restriction>
+					</simpleType>
+				</element>
+			</sequence>
+			<attribute name="kind" type="kindType"/>
+		</complexType>
+	</element>
+	<simpleType name="statusType">
+		<restriction base="string">
+			<enumeration value="in-progress"/>
+			<enumeration value="N/A"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="kindType">
+		<restriction base="statusType"/>
+	</simpleType>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := &xmlSimpleType{Name: "statusType", Base: "string", Values: []string{"in-progress", "N/A"}}
+	want := xmlTree{
+		Name: "order",
+		Type: "order",
+		Attribs: []xmlAttrib{
+			{Name: "kind", Type: "statusType", SimpleType: status},
+		},
+		Children: []*xmlTree{
+			{Name: "status", Type: "statusType", SimpleType: status},
+			{
+				Name:       "priority",
+				Type:       "priority",
+				SimpleType: &xmlSimpleType{Name: "priority", Base: "int", Values: []string{"1", "2"}},
+			},
+		},
+	}
+	if e := buildXML(t, schemas)[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+
+	gosrc := `
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type order struct {
+	XMLName  xml.Name   ` + "`xml:\"order\"`" + `
+	Kind     statusType ` + "`xml:\"kind,attr\"`" + `
+	Status   statusType ` + "`xml:\"status\"`" + `
+	Priority priority   ` + "`xml:\"priority\"`" + `
+}
+
+type statusType string
+
+const (
+	statusTypeInProgress statusType = "in-progress"
+	statusTypeNA         statusType = "N/A"
+)
+
+func (v statusType) String() string {
+	return string(v)
+}
+
+func (v statusType) IsValid() bool {
+	switch v {
+	case statusTypeInProgress, statusTypeNA:
+		return true
+	}
+	return false
+}
+
+type priority int
+
+const (
+	priority1 priority = 1
+	priority2 priority = 2
+)
+
+func (v priority) String() string {
+	return fmt.Sprint(int(v))
+}
+
+func (v priority) IsValid() bool {
+	switch v {
+	case priority1, priority2:
+		return true
+	}
+	return false
+}
+`
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+		t.Log(want)
+	}
+
+	got = generateFromXSD(t, xsd, generator{strict: true})
+	for _, m := range []string{
+		"func(v*statusType)UnmarshalText(",
+		"func(v*priority)UnmarshalText(",
+		"b,err:=strconv.Atoi(strings.TrimSpace(string(text)))",
+	} {
+		if !strings.Contains(got, m) {
+			t.Errorf("Strict mode source lacks %s", m)
+		}
+	}
+}
+
+func TestNamedSimpleTypes(t *testing.T) {
+	xsd := `<schema>
+	<element name="tag">
+		<complexType>
+			<simpleContent>
+				<extension base="nidType">
+					<attribute name="created" type="timestampType"/>
+				</extension>
+			</simpleContent>
+		</complexType>
+	</element>
+	<simpleType name="nidType">
+		<restriction base="string">
+			<pattern value="[0-9a-zA-Z\-]+"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="shortNidType">
+		<restriction base="nidType">
+			<maxLength value="8"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="timestampType">
+		<restriction base="dateTime"/>
+	</simpleType>
+	<element name="short" type="shortNidType"/>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tst := range []struct {
+		namedTypes bool
+		gosrc      string
+	}{
+		{
+			gosrc: `
+import (
+	"encoding/xml"
+
+	"github.com/ivarg/goxsd/xsdtype"
+)
+
+type tag struct {
+	XMLName xml.Name         ` + "`xml:\"tag\"`" + `
+	Created xsdtype.DateTime ` + "`xml:\"created,attr\"`" + `
+	Value   string           ` + "`xml:\",chardata\"`" + `
+}
+
+type short struct {
+	XMLName xml.Name ` + "`xml:\"short\"`" + `
+	Value   string   ` + "`xml:\",chardata\"`" + `
+}
+`,
+		},
+		{
+			namedTypes: true,
+			gosrc: `
+import (
+	"encoding/xml"
+
+	"github.com/ivarg/goxsd/xsdtype"
+)
+
+type tag struct {
+	XMLName xml.Name      ` + "`xml:\"tag\"`" + `
+	Created timestampType ` + "`xml:\"created,attr\"`" + `
+	Value   nidType       ` + "`xml:\",chardata\"`" + `
+}
+
+type nidType string
+
+type timestampType xsdtype.DateTime
+
+func (v timestampType) MarshalText() ([]byte, error) {
+	return xsdtype.DateTime(v).MarshalText()
+}
+
+func (v *timestampType) UnmarshalText(b []byte) error {
+	return (*xsdtype.DateTime)(v).UnmarshalText(b)
+}
+
+type short struct {
+	XMLName xml.Name     ` + "`xml:\"short\"`" + `
+	Value   shortNidType ` + "`xml:\",chardata\"`" + `
+}
+
+type shortNidType string
+`,
+		},
+	} {
+		bldr := newBuilder(schemas)
+		bldr.namedTypes = tst.namedTypes
+		roots, err := bldr.buildXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := (generator{}).do(&out, roots); err != nil {
+			t.Fatal(err)
+		}
+		out = removeComments(out)
+		got := strings.Join(strings.Fields(out.String()), "")
+		if want := strings.Join(strings.Fields(tst.gosrc), ""); got != want {
+			t.Errorf("Unexpected generated Go source, namedTypes: %v", tst.namedTypes)
+			t.Log(out.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="id" type="nidType"/>
+				<element name="item" type="itemType" maxOccurs="unbounded"/>
+			</sequence>
+			<attribute name="status" type="statusType"/>
+		</complexType>
+	</element>
+	<complexType name="itemType">
+		<simpleContent>
+			<extension base="string">
+				<attribute name="quantity" type="qtyType"/>
+			</extension>
+		</simpleContent>
+	</complexType>
+	<simpleType name="nidType">
+		<restriction base="string">
+			<pattern value="\i\c*"/>
+			<maxLength value="8"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="qtyType">
+		<restriction base="int">
+			<minInclusive value="1"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="statusType">
+		<restriction base="string">
+			<enumeration value="open"/>
+			<enumeration value="closed"/>
+		</restriction>
+	</simpleType>
+</schema>`
+
+	without := generateFromXSD(t, xsd, generator{})
+	if strings.Contains(without, "Validate") || strings.Contains(without, "validateNidType") {
+		t.Error("Unexpected validation generated without the validate option")
+	}
+
+	got := generateFromXSD(t, xsd, generator{validate: true})
+	for _, want := range []string{
+		`func (v order) Validate() error {
+	return errors.Join(v.validate("/order")...)
+}`,
+		`errs = append(errs, v.Status.validate(path+"/@status")...)`,
+		`errs = append(errs, validateNidType(v.ID, path+"/id")...)`,
+		`for i, x := range v.Item {
+		p := fmt.Sprintf("%s/item[%d]", path, i+1)
+		errs = append(errs, x.validate(p)...)
+	}`,
+		`errs = append(errs, validateQtyType(v.Quantity, path+"/@quantity")...)`,
+		`func validateNidType(v string, path string) []error {`,
+		`if utf8.RuneCountInString(x) > 8 {
+		errs = append(errs, fmt.Errorf("%s: %q violates %s", path, x, "maxLength 8"))
+	}`,
+		"regexp.MustCompile(`^(?:(?:[_:\\p{L}][\\-._:\\p{L}\\p{N}]*))$`)",
+		`if x < 1 {
+		errs = append(errs, fmt.Errorf("%s: %v violates %s", path, x, "minInclusive 1"))
+	}`,
+		`if !v.IsValid() {
+		errs = append(errs, fmt.Errorf("%s: %q violates %s", path, x, "enumeration"))
+	}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+}
+
+func TestPatternWarnings(t *testing.T) {
+	xsd := `<schema>
+	<simpleType name="consonant">
+		<restriction base="string">
+			<pattern value="[a-z-[aeiou]]"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="shortConsonant">
+		<restriction base="consonant">
+			<maxLength value="1"/>
+		</restriction>
+	</simpleType>
+	<element name="letters">
+		<complexType>
+			<sequence>
+				<element name="a" type="consonant"/>
+				<element name="b" type="shortConsonant"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bldr := newBuilder(schemas)
+	if _, err := bldr.buildXML(); err != nil {
+		t.Fatal(err)
+	}
+	want := `test.xsd:2: simpleType "consonant": pattern "[a-z-[aeiou]]" cannot be translated into a Go regular expression, and is not validated`
+	if len(bldr.warnings) != 1 || bldr.warnings[0].Error() != want {
+		t.Errorf("got warnings %v, want %s", bldr.warnings, want)
+	}
+}
+
+func TestXsdRegexp(t *testing.T) {
+	for _, tst := range []struct {
+		patterns []string
+		re       string
+		ok       bool
+	}{
+		{[]string{`[0-9]{3}`}, `^(?:(?:[0-9]{3}))$`, true},
+		{[]string{`a`, `b+`}, `^(?:(?:a)|(?:b+))$`, true},
+		{[]string{`^\d$`}, `^(?:(?:\^\d\$))$`, true},
+		{[]string{`\i\c*`}, `^(?:(?:[_:\p{L}][\-._:\p{L}\p{N}]*))$`, true},
+		{[]string{`[\i-]`}, `^(?:(?:[_:\p{L}-]))$`, true},
+		{[]string{`[a-z-[aeiou]]`}, "", false},
+		{[]string{`a`, `(`}, "", false},
+		{nil, "", false},
+	} {
+		re, ok := xsdRegexp(tst.patterns)
+		if re != tst.re || ok != tst.ok {
+			t.Errorf("xsdRegexp(%q) = %q, %v; want %q, %v", tst.patterns, re, ok, tst.re, tst.ok)
+		}
+	}
+}
+
+func TestValidateOccurrences(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="item" type="string" minOccurs="2" maxOccurs="5"/>
+				<element name="tag" type="string" minOccurs="0" maxOccurs="unbounded"/>
+				<sequence maxOccurs="3">
+					<element name="line" type="string" maxOccurs="2"/>
+				</sequence>
+			</sequence>
+			<attribute name="id" type="string" use="required"/>
+			<attribute name="count" type="int" use="required"/>
+			<attribute name="note" type="string"/>
+		</complexType>
+	</element>
+</schema>`
+
+	got := generateFromXSD(t, xsd, generator{validate: true, optional: true})
+	for _, want := range []string{
+		`if v.ID == nil {
+		errs = append(errs, fmt.Errorf("%s: missing required attribute", path+"/@id"))
+	}`,
+		"Count *int `xml:\"count,attr,omitempty\"`",
+		`if v.Count == nil {
+		errs = append(errs, fmt.Errorf("%s: missing required attribute", path+"/@count"))
+	}`,
+		`if len(v.Item) < 2 {
+		errs = append(errs, fmt.Errorf("%s: %d occurrences violate %s", path+"/item", len(v.Item), "minOccurs 2"))
+	}
+	if len(v.Item) > 5 {
+		errs = append(errs, fmt.Errorf("%s: %d occurrences violate %s", path+"/item", len(v.Item), "maxOccurs 5"))
+	}`,
+		`if len(v.Line) > 6 {
+		errs = append(errs, fmt.Errorf("%s: %d occurrences violate %s", path+"/line", len(v.Line), "maxOccurs 6"))
+	}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+	for _, unwanted := range []string{"v.Note", "len(v.Tag)"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Generated Go source unexpectedly checks %s", unwanted)
+		}
+	}
+}
+
+func TestOptionalFields(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="id" type="string"/>
+				<element name="note" type="string" minOccurs="0"/>
+				<element name="line" type="lineType" minOccurs="0"/>
+				<element name="tag" type="string" minOccurs="0" maxOccurs="unbounded"/>
+				<sequence minOccurs="0">
+					<element name="discount" type="int"/>
+				</sequence>
+			</sequence>
+			<attribute name="code" type="string" use="required"/>
+			<attribute name="count" type="int"/>
+		</complexType>
+	</element>
+	<complexType name="lineType">
+		<attribute name="amount" type="decimal"/>
+	</complexType>
+</schema>`
+
+	for _, tst := range []struct {
+		optional bool
+		gosrc    string
+	}{
+		{
+			gosrc: `
+import "encoding/xml"
+
+type order struct {
+	XMLName  xml.Name ` + "`xml:\"order\"`" + `
+	Code     string   ` + "`xml:\"code,attr\"`" + `
+	Count    int      ` + "`xml:\"count,attr\"`" + `
+	ID       string   ` + "`xml:\"id\"`" + `
+	Note     string   ` + "`xml:\"note\"`" + `
+	Line     lineType ` + "`xml:\"line\"`" + `
+	Tag      []string ` + "`xml:\"tag\"`" + `
+	Discount int      ` + "`xml:\"discount\"`" + `
+}
+
+type lineType struct {
+	Amount float64 ` + "`xml:\"amount,attr\"`" + `
+}
+`,
+		},
+		{
+			optional: true,
+			gosrc: `
+import "encoding/xml"
+
+type order struct {
+	XMLName  xml.Name  ` + "`xml:\"order\"`" + `
+	Code     *string   ` + "`xml:\"code,attr,omitempty\"`" + `
+	Count    *int      ` + "`xml:\"count,attr,omitempty\"`" + `
+	ID       string    ` + "`xml:\"id\"`" + `
+	Note     *string   ` + "`xml:\"note,omitempty\"`" + `
+	Line     *lineType ` + "`xml:\"line,omitempty\"`" + `
+	Tag      []string  ` + "`xml:\"tag\"`" + `
+	Discount *int      ` + "`xml:\"discount,omitempty\"`" + `
+}
+
+type lineType struct {
+	Amount *float64 ` + "`xml:\"amount,attr,omitempty\"`" + `
+}
+`,
+		},
+	} {
+		got := generateFromXSD(t, xsd, generator{optional: tst.optional})
+		if want := strings.Join(strings.Fields(tst.gosrc), ""); got != want {
+			t.Errorf("Unexpected generated Go source, optional: %v", tst.optional)
+			t.Log(got)
+		}
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	xsd := `<schema>
+	<element name="values">
+		<complexType>
+			<sequence>
+				<element name="count" type="unsignedShort" maxOccurs="unbounded"/>
+				<element name="size" type="unsignedLong"/>
+				<element name="offset" type="long"/>
+				<element name="level" type="byte"/>
+				<element name="rank" type="positiveInteger"/>
+				<element name="ratio" type="float"/>
+				<element name="weight" type="double"/>
+				<element name="label" type="normalizedString"/>
+				<element name="tokens" type="NMTOKENS"/>
+				<element name="name" type="QName"/>
+				<element name="day" type="date"/>
+				<element name="data" type="base64Binary"/>
+				<element name="extra" type="anyType"/>
+			</sequence>
+			<attribute name="id" type="ID"/>
+			<attribute name="refs" type="IDREFS"/>
+			<attribute name="hash" type="hexBinary"/>
+		</complexType>
+	</element>
+</schema>`
+
+	gosrc := `
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/ivarg/goxsd/xsdtype"
+)
+
+type values struct {
+	XMLName xml.Name             ` + "`xml:\"values\"`" + `
+	ID      string               ` + "`xml:\"id,attr\"`" + `
+	Refs    idrefs               ` + "`xml:\"refs,attr\"`" + `
+	Hash    xsdtype.HexBinary    ` + "`xml:\"hash,attr\"`" + `
+	Count   []uint16             ` + "`xml:\"count\"`" + `
+	Size    uint64               ` + "`xml:\"size\"`" + `
+	Offset  int64                ` + "`xml:\"offset\"`" + `
+	Level   int8                 ` + "`xml:\"level\"`" + `
+	Rank    uint                 ` + "`xml:\"rank\"`" + `
+	Ratio   float32              ` + "`xml:\"ratio\"`" + `
+	Weight  float64              ` + "`xml:\"weight\"`" + `
+	Label   string               ` + "`xml:\"label\"`" + `
+	Tokens  nmtokens             ` + "`xml:\"tokens\"`" + `
+	Name    string               ` + "`xml:\"name\"`" + `
+	Day     xsdtype.Date         ` + "`xml:\"day\"`" + `
+	Data    xsdtype.Base64Binary ` + "`xml:\"data\"`" + `
+	Extra   anyType              ` + "`xml:\"extra\"`" + `
+}
+
+type idrefs []string
+
+func (v idrefs) MarshalText() ([]byte, error) {
+	items := make([]string, len(v))
+	for i, x := range v {
+		items[i] = string(x)
+	}
+	return []byte(strings.Join(items, " ")), nil
+}
+
+func (v *idrefs) UnmarshalText(text []byte) error {
+	var l idrefs
+	for _, f := range strings.Fields(string(text)) {
+		b := f
+		l = append(l, string(b))
+	}
+	*v = l
+	return nil
+}
+
+type nmtokens []string
+
+func (v nmtokens) MarshalText() ([]byte, error) {
+	items := make([]string, len(v))
+	for i, x := range v {
+		items[i] = string(x)
+	}
+	return []byte(strings.Join(items, " ")), nil
+}
+
+func (v *nmtokens) UnmarshalText(text []byte) error {
+	var l nmtokens
+	for _, f := range strings.Fields(string(text)) {
+		b := f
+		l = append(l, string(b))
+	}
+	*v = l
+	return nil
+}
+
+type anyType struct {
+	Attrs   []xml.Attr ` + "`xml:\",any,attr\"`" + `
+	Content string     ` + "`xml:\",innerxml\"`" + `
+}
+
+func (v anyType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	prefixes := make(map[string]string)
+	for _, a := range v.Attrs {
+		if a.Name.Space == "xmlns" {
+			prefixes[a.Value] = a.Name.Local
+		}
+	}
+	for _, a := range v.Attrs {
+		if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		if a.Name.Space == "xmlns" {
+			a.Name = xml.Name{Local: "xmlns:" + a.Name.Local}
+		} else if p, ok := prefixes[a.Name.Space]; ok {
+			a.Name = xml.Name{Local: p + ":" + a.Name.Local}
+		}
+		start.Attr = append(start.Attr, a)
+	}
+	return e.EncodeElement(struct {
+		Content string ` + "`xml:\",innerxml\"`" + `
+	}{v.Content}, start)
+}
+`
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+	}
+}
+
+func TestBuiltinTypeNames(t *testing.T) {
+	xsd := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:t" xmlns="urn:t" elementFormDefault="qualified">
+	<xs:complexType name="string">
+		<xs:sequence>
+			<xs:element name="text" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="int">
+		<xs:sequence>
+			<xs:element name="value" type="xs:int"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:element name="pair">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="first" type="string"/>
+				<xs:element name="second" type="int"/>
+				<xs:element name="note" type="xs:string"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`
+
+	gosrc := `
+import "encoding/xml"
+
+type pair struct {
+	XMLName xml.Name ` + "`xml:\"urn:t pair\"`" + `
+	First   string2  ` + "`xml:\"urn:t first\"`" + `
+	Second  int2     ` + "`xml:\"urn:t second\"`" + `
+	Note    string   ` + "`xml:\"urn:t note\"`" + `
+}
+
+type string2 struct {
+	Text string ` + "`xml:\"urn:t text\"`" + `
+}
+
+type int2 struct {
+	Value int ` + "`xml:\"urn:t value\"`" + `
+}
+`
+	got := generateFromXSD(t, xsd, generator{})
+	if want := strings.Join(strings.Fields(gosrc), ""); got != want {
+		t.Errorf("Unexpected generated Go source")
+		t.Log(got)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	xsd := `<schema>
+	<element name="invoice">
+		<complexType>
+			<sequence>
+				<element name="id" type="positiveInteger"/>
+				<element name="amount" type="amountType"/>
+				<element name="count" type="int"/>
+				<element name="rate" type="rateType"/>
+			</sequence>
+		</complexType>
+	</element>
+	<simpleType name="amountType">
+		<restriction base="decimal">
+			<totalDigits value="8"/>
+			<fractionDigits value="2"/>
+			<minInclusive value="0"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="rateType">
+		<restriction base="decimal">
+			<enumeration value="0.5"/>
+			<enumeration value="1"/>
+		</restriction>
+	</simpleType>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bldr := newBuilder(schemas)
+	bldr.bigNumbers = true
+	roots, err := bldr.buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{validate: true}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	got := strings.Join(strings.Fields(out.String()), "")
+
+	for _, want := range []string{
+		`type invoice struct {
+	XMLName xml.Name        ` + "`xml:\"invoice\"`" + `
+	ID      xsdtype.Integer ` + "`xml:\"id\"`" + `
+	Amount  xsdtype.Decimal ` + "`xml:\"amount\"`" + `
+	Count   int             ` + "`xml:\"count\"`" + `
+	Rate    rateType        ` + "`xml:\"rate\"`" + `
+}`,
+		`type rateType xsdtype.Decimal`,
+		`var (
+	rateType05 rateType = rateType(xsdtype.MustParseDecimal("0.5"))
+	rateType1  rateType = rateType(xsdtype.MustParseDecimal("1"))
+)`,
+		`func (v rateType) IsValid() bool {
+	for _, x := range []rateType{rateType05, rateType1} {
+		if xsdtype.Decimal(v).Cmp(xsdtype.Decimal(x)) == 0 {
+			return true
+		}
+	}
+	return false
+}`,
+		`errs = append(errs, validateAmountType(v.Amount, path+"/amount")...)`,
+		`if x.Cmp(xsdtype.MustParseDecimal("0")) < 0 {`,
+		`if x.TotalDigits() > 8 {`,
+		`if x.FractionDigits() > 2 {`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	xsd := `<schema>
+	<element name="box">
+		<complexType>
+			<sequence>
+				<element name="sizes" type="fewSizes"/>
+				<element name="days">
+					<simpleType>
+						<list itemType="date"/>
+					</simpleType>
+				</element>
+				<element name="codes">
+					<simpleType>
+						<list itemType="code"/>
+					</simpleType>
+				</element>
+			</sequence>
+			<attribute name="colors" use="required">
+				<simpleType>
+					<list>
+						<simpleType>
+							<restriction base="string">
+								<enumeration value="red"/>
+								<enumeration value="blue"/>
+							</restriction>
+						</simpleType>
+					</list>
+				</simpleType>
+			</attribute>
+		</complexType>
+	</element>
+	<simpleType name="sizes">
+		<list itemType="int"/>
+	</simpleType>
+	<simpleType name="fewSizes">
+		<restriction base="sizes">
+			<maxLength value="3"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="code">
+		<union memberTypes="int boolean"/>
+	</simpleType>
+</schema>`
+
+	got := generateFromXSD(t, xsd, generator{validate: true})
+	for _, want := range []string{
+		`type box struct {
+	XMLName xml.Name ` + "`xml:\"box\"`" + `
+	Colors  colors   ` + "`xml:\"colors,attr\"`" + `
+	Sizes   fewSizes ` + "`xml:\"sizes\"`" + `
+	Days    days     ` + "`xml:\"days\"`" + `
+	Codes   codes    ` + "`xml:\"codes\"`" + `
+}`,
+		`type fewSizes []int`,
+		`type days []xsdtype.Date`,
+		`type colors []colorsItem`,
+		`type colorsItem string`,
+		`func (v *fewSizes) UnmarshalText(text []byte) error {
+	var l fewSizes
+	for _, f := range strings.Fields(string(text)) {
+		b, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		l = append(l, int(b))
+	}
+	*v = l
+	return nil
+}`,
+		`func (v *codes) UnmarshalText(text []byte) error {
+	var l codes
+	for _, f := range strings.Fields(string(text)) {
+		var x code
+		if err := x.UnmarshalText([]byte(f)); err != nil {
+			return err
+		}
+		l = append(l, x)
+	}
+	*v = l
+	return nil
+}`,
+		`items[i] = fmt.Sprint(int(x))`,
+		`if len(v) > 3 {
+	errs = append(errs, fmt.Errorf("%s: %d items violate %s", path, len(v), "maxLength 3"))
+}`,
+		`errs = append(errs, v.Colors.validate(path+"/@colors")...)`,
+		`for _, x := range v {
+	errs = append(errs, x.validate(path)...)
+}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+}
+
+func TestUnions(t *testing.T) {
+	xsd := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="shipped" type="shipDate"/>
+				<element name="size">
+					<simpleType>
+						<union memberTypes="int boolean"/>
+					</simpleType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<simpleType name="shipDate">
+		<union memberTypes="date">
+			<simpleType>
+				<restriction base="token">
+					<enumeration value="unknown"/>
+				</restriction>
+			</simpleType>
+			<simpleType>
+				<restriction base="string">
+					<pattern value="[0-9]{4}-Q[1-4]"/>
+				</restriction>
+			</simpleType>
+		</union>
+	</simpleType>
+</schema>`
+
+	got := generateFromXSD(t, xsd, generator{validate: true})
+	for _, want := range []string{
+		`type order struct {
+	XMLName xml.Name ` + "`xml:\"order\"`" + `
+	Shipped shipDate ` + "`xml:\"shipped\"`" + `
+	Size    size     ` + "`xml:\"size\"`" + `
+}`,
+		`type shipDate string`,
+		`type shipDateMember2 string`,
+		`func (v shipDate) AsDate() (x xsdtype.Date, ok bool) {
+	var b xsdtype.Date
+	if err := b.UnmarshalText([]byte(string(v))); err != nil {
+		return
+	}
+	x = xsdtype.Date(b)
+	return x, true
+}`,
+		`func (v shipDate) AsShipDateMember2() (x shipDateMember2, ok bool) {
+	b := string(v)
+	x = shipDateMember2(b)
+	return x, len(x.validate("")) == 0
+}`,
+		`func (v shipDate) AsString() (x string, ok bool) {
+	b := string(v)
+	x = string(b)
+	return x, len(validateShipDateMember3(x, "")) == 0
+}`,
+		`func (v *size) UnmarshalText(text []byte) error {
+	u := size(text)
+	if u == "" {
+		*v = u
+		return nil
+	}
+	if _, ok := u.AsInt(); ok {
+		*v = u
+		return nil
+	}
+	if _, ok := u.AsBool(); ok {
+		*v = u
+		return nil
+	}
+	return fmt.Errorf("invalid size value: %q", text)
+}`,
+		`if _, ok := v.AsDate(); ok {
+	} else if _, ok := v.AsShipDateMember2(); ok {
+	} else if _, ok := v.AsString(); ok {
+	} else {
+		errs = append(errs, fmt.Errorf("%s: %q violates %s", path, string(v), "memberTypes"))
+	}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+
+	// The accessors check the facets of the members when not validating.
+	got = generateFromXSD(t, xsd, generator{})
+	if want := "funcvalidateShipDateMember3(vstring,pathstring)[]error{"; !strings.Contains(got, want) {
+		t.Errorf("Generated Go source lacks %s", want)
+	}
+}
+
+func TestEmbedBase(t *testing.T) {
+	xsd := `<schema>
+	<complexType name="shape">
+		<sequence>
+			<element name="id" type="string"/>
+		</sequence>
+		<attribute name="color" type="string"/>
+	</complexType>
+	<complexType name="circle">
+		<complexContent>
+			<extension base="shape">
+				<sequence>
+					<element name="radius" type="int"/>
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="circle" type="circle" maxOccurs="unbounded"/>
+			</sequence>
+		</complexType>
+	</element>
+	<element name="ring" type="circle"/>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bldr := newBuilder(schemas)
+	bldr.embedBase = true
+	roots, err := bldr.buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{validate: true}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	got := strings.Join(strings.Fields(out.String()), "")
+
+	for _, want := range []string{
+		`type circle struct {
+	shape
+	Radius int ` + "`xml:\"radius\"`" + `
+}`,
+		`type shape struct {
+	Color string ` + "`xml:\"color,attr\"`" + `
+	ID    string ` + "`xml:\"id\"`" + `
+}`,
+		`type ring struct {
+	XMLName xml.Name ` + "`xml:\"ring\"`" + `
+	circle
+}`,
+		`func (v circle) validate(path string) []error {
+	var errs []error
+	errs = append(errs, v.shape.validate(path)...)
+	return errs
+}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+}
+
+func TestComplexRestriction(t *testing.T) {
+	xsd := `<schema>
+	<complexType name="person">
+		<sequence>
+			<element name="name" type="string"/>
+			<element name="email" type="string" minOccurs="0"/>
+		</sequence>
+		<attribute name="id" type="string"/>
+		<attribute name="nick" type="string"/>
+	</complexType>
+	<complexType name="employee">
+		<complexContent>
+			<extension base="person">
+				<attribute name="dept" type="string"/>
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="contact">
+		<complexContent>
+			<restriction base="employee">
+				<sequence>
+					<element name="name" type="string"/>
+				</sequence>
+				<attribute name="id" type="string" use="required"/>
+				<attribute name="nick" use="prohibited"/>
+			</restriction>
+		</complexContent>
+	</complexType>
+	<element name="book">
+		<complexType>
+			<sequence>
+				<element name="contact" type="contact"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	schemas, err := parse(strings.NewReader(xsd), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xmlTree{
+		Name:  "contact",
+		Type:  "contact",
+		Named: true,
+		Attribs: []xmlAttrib{
+			{Name: "id", Type: "string", Required: true},
+			{Name: "dept", Type: "string"},
+		},
+		Children: []*xmlTree{
+			{Name: "name", Type: "string"},
+		},
+	}
+	if e := buildXML(t, schemas)[0].Children[0]; !reflect.DeepEqual(want, *e) {
+		t.Errorf("Unexpected XML element: %s", e.Name)
+		pretty.Println(want)
+		pretty.Println(e)
+	}
+}
+
+func TestComplexRestrictionNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.xsd": `<schema targetNamespace="urn:main" xmlns:m="urn:main" xmlns:b="urn:base">
+	<import namespace="urn:base" schemaLocation="base.xsd"/>
+	<complexType name="plainItem">
+		<complexContent>
+			<restriction base="b:item">
+				<attribute name="color" use="prohibited"/>
+				<attribute name="size" type="int" use="required"/>
+				<attribute name="lang" use="prohibited"/>
+			</restriction>
+		</complexContent>
+	</complexType>
+	<element name="item" type="m:plainItem"/>
+</schema>`,
+		"base.xsd": `<schema targetNamespace="urn:base">
+	<complexType name="item">
+		<attribute name="color" type="string"/>
+		<attribute name="size" type="string"/>
+		<attribute name="lang" type="string" form="qualified"/>
+	</complexType>
+</schema>`,
+	}
+	for name, xsd := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xsd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xmlAttrib{
+		{Name: "size", Type: "int", Namespace: "urn:main", Required: true},
+		{Name: "lang", Type: "string", Namespace: "urn:base", Qualified: true},
+	}
+	if got := buildXML(t, schemas)[0].Embed.Attribs; !reflect.DeepEqual(want, got) {
+		t.Errorf("Unexpected attributes")
+		pretty.Println(got)
+	}
+}
+
+func TestAbstractTypes(t *testing.T) {
+	xsd := `<schema>
+	<complexType name="shape" abstract="true">
+		<sequence>
+			<element name="id" type="string"/>
+		</sequence>
+	</complexType>
+	<complexType name="circle">
+		<complexContent>
+			<extension base="shape">
+				<sequence>
+					<element name="radius" type="int"/>
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="polygon" abstract="true">
+		<complexContent>
+			<extension base="shape"/>
+		</complexContent>
+	</complexType>
+	<complexType name="square">
+		<complexContent>
+			<extension base="polygon">
+				<sequence>
+					<element name="side" type="int"/>
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="shape" type="shape" maxOccurs="unbounded"/>
+				<element name="frame" type="shape" minOccurs="0"/>
+			</sequence>
+		</complexType>
+	</element>
+	<element name="figure" type="shape"/>
+</schema>`
+
+	got := generateFromXSD(t, xsd, generator{})
+	for _, want := range []string{
+		`type drawing struct {
+	XMLName xml.Name   ` + "`xml:\"drawing\"`" + `
+	Shape   []anyShape ` + "`xml:\"shape\"`" + `
+	Frame   *anyShape  ` + "`xml:\"frame,omitempty\"`" + `
+}`,
+		`type anyShape struct {
+	Value anyShapeValue
+}`,
+		`type anyShapeValue interface {
+	isAnyShape()
+}`,
+		`func (circle) isAnyShape() {}`,
+		`func (square) isAnyShape() {}`,
+		`var anyShapeTypes = map[xml.Name]func() anyShapeValue{
+	{Local: "circle"}: func() anyShapeValue { return new(circle) },
+	{Local: "square"}: func() anyShapeValue { return new(square) },
+}`,
+		`typ, ok := xsdtype.TypeName(start, a.Value, names)`,
+		`case square, *square:
+	typ, ns = "square", ""`,
+		`case nil:
+	return fmt.Errorf("no value of element %s, of an abstract type", start.Name.Local)`,
+		`type circle struct {
+	ID     string ` + "`xml:\"id\"`" + `
+	Radius int    ` + "`xml:\"radius\"`" + `
+}`,
+		`type figure struct {
+	XMLName xml.Name ` + "`xml:\"figure\"`" + `
+	anyShape
+}`,
+		`func (v figure) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "figure"}
+	return v.anyShape.MarshalXML(e, start)
+}`,
+		`func (v *figure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Local != "figure" {
+		return fmt.Errorf("expected element type <figure> but have <%s>", start.Name.Local)
+	}
+	v.XMLName = start.Name
+	return v.anyShape.UnmarshalXML(d, start)
+}`,
+	} {
+		if !strings.Contains(got, strings.Join(strings.Fields(want), "")) {
+			t.Errorf("Generated Go source lacks %s", want)
+		}
+	}
+	if strings.Contains(got, "typepolygon") || strings.Contains(got, "typeshapestruct") {
+		t.Error("Generated Go source has structs for abstract types")
+	}
+}
+
+// roundTripMain decodes each of the documents into a value of the root type,
+// validates the value if it has a Validate method, and encodes it, to be
+// decoded again into the same value. It prints the encoded documents, if
+// asked to, rather than ok.
+const roundTripMain = `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+)
+
+var docs = []string{%s}
+
+const printEncoded = %t
+
+func main() {
+	for _, doc := range docs {
+		var v %s
+		if err := xml.Unmarshal([]byte(doc), &v); err != nil {
+			fmt.Println("decode:", err)
+			continue
+		}
+		if x, ok := interface{}(v).(interface{ Validate() error }); ok {
+			if err := x.Validate(); err != nil {
+				fmt.Println("validate:", err)
+				continue
+			}
+		}
+		out, err := xml.Marshal(v)
+		if err != nil {
+			fmt.Println("encode:", err)
+			continue
+		}
+		var w %[3]s
+		if err := xml.Unmarshal(out, &w); err != nil {
+			fmt.Printf("decode %%s: %%v\n", out, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, w) {
+			fmt.Printf("changed: %%s\n", out)
+			continue
+		}
+		if printEncoded {
+			fmt.Println(string(out))
+			continue
+		}
+		fmt.Println("ok")
+	}
+}
+`
+
+// TestRoundTrip compiles the code generated from schemas, and has it decode,
+// validate and encode documents, which must decode again into the same
+// values. It needs the go command, and is skipped in short mode.
+func TestRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compiling generated code in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command to compile generated code with")
+	}
+
+	tests := []struct {
+		name    string
+		xsd     string
+		named   bool // namedTypes of the builder
+		gen     generator
+		root    string
+		docs    []string
+		encoded bool // want the encoded documents rather than ok
+		want    []string
+	}{
+		{
+			name: "choices",
+			xsd: `<schema>
+	<element name="shape">
+		<complexType>
+			<sequence>
+				<element name="id" type="string"/>
+				<choice maxOccurs="unbounded">
+					<element name="circle" type="float"/>
+					<sequence>
+						<element name="width" type="int"/>
+						<element name="height" type="int"/>
+					</sequence>
+				</choice>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			gen:  generator{choiceIface: true, validate: true},
+			root: "shape",
+			docs: []string{
+				`<shape><id>a</id><circle>1.5</circle><width>2</width><height>3</height><circle>2</circle></shape>`,
+				`<shape><id>a</id><square>1</square></shape>`,
+			},
+			want: []string{
+				"ok",
+				"decode: unexpected element square in element shape",
+			},
+		},
+		{
+			name: "choices of simple types",
+			xsd: `<schema>
+	<simpleType name="sizes">
+		<list itemType="int"/>
+	</simpleType>
+	<simpleType name="shortSizes">
+		<restriction base="sizes">
+			<maxLength value="2"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="color">
+		<restriction base="string">
+			<enumeration value="red"/>
+			<enumeration value="blue"/>
+		</restriction>
+	</simpleType>
+	<element name="log">
+		<complexType>
+			<choice maxOccurs="unbounded">
+				<element name="sizes" type="shortSizes"/>
+				<element name="day" type="date"/>
+				<element name="data" type="hexBinary"/>
+				<element name="color" type="color"/>
+				<element name="tokens" type="NMTOKENS"/>
+				<element name="extra" type="anyType"/>
+			</choice>
+		</complexType>
+	</element>
+</schema>`,
+			named: true,
+			gen:   generator{choiceIface: true, strict: true, validate: true},
+			root:  "log",
+			docs: []string{
+				`<log><sizes>1 2</sizes><day>2024-05-01Z</day><data>0aff</data><color>blue</color><tokens>a b</tokens><extra a="1"><x>y</x></extra></log>`,
+				`<log><sizes>1 2 3</sizes></log>`,
+				`<log><color>green</color></log>`,
+				`<log><day>2024-13-01</day></log>`,
+			},
+			want: []string{
+				"ok",
+				"validate: /log/sizes: 3 items violate maxLength 2",
+				"decode: invalid color value: green",
+				"decode: xsdtype: invalid date \"2024-13-01\": out of range",
+			},
+		},
+		{
+			name: "strict enumerations",
+			xsd: `<schema>
+	<simpleType name="color">
+		<restriction base="string">
+			<enumeration value="red"/>
+			<enumeration value="blue"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="level">
+		<restriction base="int">
+			<enumeration value="1"/>
+			<enumeration value="2"/>
+		</restriction>
+	</simpleType>
+	<element name="paint">
+		<complexType>
+			<sequence>
+				<element name="color" type="color"/>
+				<element name="shade" type="color" minOccurs="0"/>
+				<element name="mix">
+					<simpleType>
+						<list itemType="color"/>
+					</simpleType>
+				</element>
+			</sequence>
+			<attribute name="level" type="level"/>
+		</complexType>
+	</element>
+</schema>`,
+			named: true,
+			gen:   generator{strict: true},
+			root:  "paint",
+			docs: []string{
+				`<paint level="2"><color>red</color><mix>red blue</mix></paint>`,
+				`<paint level="3"><color>red</color><mix/></paint>`,
+				`<paint><color>green</color><mix/></paint>`,
+				`<paint><color>blue</color><mix>blue green</mix></paint>`,
+				`<paint><color>red</color><mix/></paint>`,
+			},
+			want: []string{
+				"ok",
+				"decode: invalid level value: 3",
+				"decode: invalid color value: green",
+				"decode: invalid color value: green",
+				"ok",
+			},
+		},
+		{
+			name: "validation",
+			xsd: `<schema>
+	<simpleType name="code">
+		<restriction base="string">
+			<pattern value="[A-Z]{2}[0-9]+"/>
+			<maxLength value="6"/>
+		</restriction>
+	</simpleType>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="item" maxOccurs="3">
+					<complexType>
+						<attribute name="code" type="code" use="required"/>
+						<attribute name="quantity" use="required">
+							<simpleType>
+								<restriction base="int">
+									<minInclusive value="1"/>
+								</restriction>
+							</simpleType>
+						</attribute>
+					</complexType>
+				</element>
+				<element name="note" type="code" minOccurs="0"/>
+			</sequence>
+			<attribute name="ref" type="code"/>
+		</complexType>
+	</element>
+</schema>`,
+			gen:  generator{validate: true, optional: true},
+			root: "order",
+			docs: []string{
+				`<order><item code="AB1" quantity="2"/></order>`,
+				`<order ref="AB12"><item code="AB1" quantity="1"/><note>CD2</note></order>`,
+				`<order ref="ab"><item code="ABC" quantity="0"/><item quantity="1"/></order>`,
+				`<order><item code="AB1" quantity="1"/><item code="AB1" quantity="1"/><item code="AB1" quantity="1"/><item code="AB1" quantity="1"/></order>`,
+			},
+			want: []string{
+				"ok",
+				"ok",
+				"validate: /order/@ref: \"ab\" violates pattern [A-Z]{2}[0-9]+",
+				"/order/item[1]/@code: \"ABC\" violates pattern [A-Z]{2}[0-9]+",
+				"/order/item[1]/@quantity: 0 violates minInclusive 1",
+				"/order/item[2]/@code: missing required attribute",
+				"validate: /order/item: 4 occurrences violate maxOccurs 3",
+			},
+		},
+		{
+			name: "lists and unions",
+			xsd: `<schema>
+	<simpleType name="sizeList">
+		<list itemType="int"/>
+	</simpleType>
+	<simpleType name="sizes">
+		<restriction base="sizeList">
+			<maxLength value="3"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="quarter">
+		<restriction base="string">
+			<pattern value="[0-9]{4}-Q[1-4]"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="due">
+		<union memberTypes="date quarter"/>
+	</simpleType>
+	<element name="plan">
+		<complexType>
+			<sequence>
+				<element name="sizes" type="sizes"/>
+				<element name="due">
+					<simpleType>
+						<list itemType="due"/>
+					</simpleType>
+				</element>
+				<element name="when" type="due" minOccurs="0"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			gen:  generator{validate: true},
+			root: "plan",
+			docs: []string{
+				`<plan><sizes>1 2 3</sizes><due>2024-05-01 2024-Q2</due></plan>`,
+				`<plan><sizes>1 2 3 4</sizes><due/></plan>`,
+				`<plan><sizes>1 x</sizes><due/></plan>`,
+				`<plan><sizes/><due>2024-Q5</due></plan>`,
+				`<plan><sizes>1</sizes><due/></plan>`,
+			},
+			want: []string{
+				"ok",
+				"validate: /plan/sizes: 4 items violate maxLength 3",
+				"decode: strconv.Atoi: parsing \"x\": invalid syntax",
+				"decode: invalid due value: \"2024-Q5\"",
+				"ok",
+			},
+		},
+		{
+			name: "complex restriction",
+			xsd: `<schema>
+	<complexType name="person">
+		<sequence>
+			<element name="name" type="string"/>
+			<element name="email" type="string" minOccurs="0"/>
+		</sequence>
+		<attribute name="id" type="string"/>
+		<attribute name="nick" type="string"/>
+	</complexType>
+	<complexType name="contact">
+		<complexContent>
+			<restriction base="person">
+				<sequence>
+					<element name="name" type="string"/>
+				</sequence>
+				<attribute name="nick" use="prohibited"/>
+				<attribute name="id" type="string" use="required"/>
+			</restriction>
+		</complexContent>
+	</complexType>
+	<element name="contact" type="contact"/>
+</schema>`,
+			gen:  generator{validate: true, optional: true},
+			root: "contact",
+			docs: []string{
+				`<contact id="1"><name>Ann</name></contact>`,
+				`<contact nick="a"><name>Ann</name></contact>`,
+				`<contact id=""><name>Ann</name></contact>`,
+			},
+			want: []string{
+				"ok",
+				"validate: /contact/@id: missing required attribute",
+				"ok",
+			},
+		},
+		{
+			name: "abstract types",
+			xsd: `<schema targetNamespace="urn:shapes" xmlns:s="urn:shapes" elementFormDefault="qualified">
+	<complexType name="shape" abstract="true">
+		<sequence>
+			<element name="id" type="string"/>
+		</sequence>
+	</complexType>
+	<complexType name="circle">
+		<complexContent>
+			<extension base="s:shape">
+				<sequence>
+					<element name="radius" type="int"/>
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="square">
+		<complexContent>
+			<extension base="s:shape">
+				<sequence>
+					<element name="side" type="int"/>
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="shape" type="s:shape" maxOccurs="unbounded"/>
+				<element name="frame" type="s:shape" minOccurs="0"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			gen:  generator{validate: true},
+			root: "drawing",
+			docs: []string{
+				`<drawing xmlns="urn:shapes" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:s="urn:shapes"><shape xsi:type="s:circle"><id>a</id><radius>1</radius></shape><shape xsi:type="square"><id>b</id><side>2</side></shape></drawing>`,
+				`<drawing xmlns="urn:shapes" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><shape xsi:type="circle"><id>a</id><radius>1</radius></shape><frame xsi:type="square"><id>b</id><side>2</side></frame></drawing>`,
+				`<drawing xmlns="urn:shapes" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><shape xsi:type="triangle"><id>a</id></shape></drawing>`,
+			},
+			want: []string{
+				"ok",
+				"ok",
+				"decode: unknown xsi:type \"triangle\" of element shape",
+			},
+		},
+		{
+			name: "anyType in a namespace",
+			xsd: `<schema targetNamespace="urn:t" elementFormDefault="qualified">
+	<element name="note">
+		<complexType>
+			<sequence>
+				<element name="extra" type="anyType" maxOccurs="unbounded"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			root: "note",
+			docs: []string{
+				`<note xmlns="urn:t"><extra xmlns="urn:t" xmlns:p="http://example.com/p" p:a="1"><p:x/></extra></note>`,
+				`<note xmlns="urn:t"><extra xmlns="urn:t" xmlns:q="urn:q" xml:lang="en"><q:y>z</q:y></extra></note>`,
+			},
+			want: []string{
+				"ok",
+				"ok",
+			},
+		},
+		{
+			name: "unqualified local elements",
+			xsd: `<schema targetNamespace="urn:o" xmlns:o="urn:o">
+	<element name="item">
+		<complexType>
+			<sequence>
+				<element name="sku" type="string"/>
+			</sequence>
+			<attribute name="id" type="string"/>
+		</complexType>
+	</element>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="ref" type="string"/>
+				<element ref="o:item" maxOccurs="unbounded"/>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			root: "order",
+			docs: []string{
+				`<o:order xmlns:o="urn:o"><ref>a</ref><o:item id="1"><sku>x</sku></o:item></o:order>`,
+			},
+			encoded: true,
+			want: []string{
+				`<ns:order xmlns:ns="urn:o" xmlns=""><ref>a</ref><ns:item xmlns:ns="urn:o" xmlns="" id="1"><sku>x</sku></ns:item></ns:order>`,
+			},
+		},
+		{
+			name: "exported names with prefix",
+			xsd: `<schema>
+	<simpleType name="color">
+		<restriction base="string">
+			<enumeration value="red"/>
+			<enumeration value="blue"/>
+		</restriction>
+	</simpleType>
+	<simpleType name="sizes">
+		<list itemType="int"/>
+	</simpleType>
+	<simpleType name="due">
+		<union memberTypes="date color"/>
+	</simpleType>
+	<simpleType name="code">
+		<restriction base="string">
+			<maxLength value="4"/>
+		</restriction>
+	</simpleType>
+	<complexType name="label">
+		<simpleContent>
+			<extension base="color">
+				<attribute name="code" type="code"/>
+			</extension>
+		</simpleContent>
+	</complexType>
+	<element name="item">
+		<complexType>
+			<sequence>
+				<element name="label" type="label"/>
+				<element name="size" type="sizes"/>
+			</sequence>
+			<attribute name="color" type="color"/>
+			<attribute name="sizes" type="sizes"/>
+			<attribute name="due" type="due"/>
+			<attribute name="code" type="code"/>
+		</complexType>
+	</element>
+</schema>`,
+			named: true,
+			gen:   generator{exported: true, prefix: "x", strict: true, validate: true},
+			root:  "XItem",
+			docs: []string{
+				`<item color="red" sizes="1 2" due="2024-05-01" code="ab"><label code="cd">blue</label><size>3</size></item>`,
+				`<item due="green"><label>red</label><size/></item>`,
+				`<item><label code="abcde">red</label><size/></item>`,
+			},
+			want: []string{
+				"ok",
+				"decode: invalid XDue value: \"green\"",
+				"validate: /item/label/@code: \"abcde\" violates maxLength 4",
+			},
+		},
+	}
+
+	for _, tst := range tests {
+		schemas, err := parse(strings.NewReader(tst.xsd), "test.xsd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bldr := newBuilder(schemas)
+		bldr.namedTypes = tst.named
+		roots, err := bldr.buildXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir, err := os.MkdirTemp(".", "_roundtrip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		var src bytes.Buffer
+		tst.gen.pkg = "main"
+		if err := tst.gen.do(&src, roots); err != nil {
+			t.Fatal(err)
+		}
+		docs := make([]string, len(tst.docs))
+		for i, d := range tst.docs {
+			docs[i] = strconv.Quote(d)
+		}
+		prog := fmt.Sprintf(roundTripMain, strings.Join(docs, ", "), tst.encoded, tst.root)
+		if err := os.WriteFile(filepath.Join(dir, "gen.go"), src.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(prog), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command("go", "run", "./"+filepath.Base(dir)).CombinedOutput()
+		if err != nil {
+			t.Errorf("%s: %v\n%s", tst.name, err, out)
+			continue
+		}
+		got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("%s: unexpected round trips", tst.name)
+			for _, l := range got {
+				t.Log(l)
+			}
+		}
+	}
+}
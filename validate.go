@@ -0,0 +1,563 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Validate methods generated for a struct, checking every value of the
+	// struct, and the structs it holds, against the facets of its type. A
+	// root element wraps the type it embeds, while a derived type validates
+	// its base type first. A sequence within a choice is only validated as
+	// part of the struct holding the choice.
+	validateStruct = `{{ define "ValidateStruct" }}{{ $t := typeName .Type }}{{ if not .Sequence }}
+// Validate checks v against the constraints of its XSD type, and reports
+// every violation found
+func (v {{ $t }}) Validate() error {
+	return errors.Join(v.{{ if onlyEmbeds . }}{{ typeName .Embed.Type }}.{{ end }}validate("{{ if isRoot . }}/{{ .Name }}{{ end }}")...)
+}
+{{ end }}{{ if not (onlyEmbeds .) }}
+func (v {{ $t }}) validate(path string) []error {
+	var errs []error
+{{ validateFields . }}	return errs
+}
+{{ end }}{{ end }}`
+
+	// Validate method generated for the holder of a choice, checking the
+	// chosen alternative
+	validateChoice = `{{ define "ValidateChoice" }}{{ $t := typeName .Type }}
+func (c {{ $t }}) validate(path string) []error {
+	var errs []error
+{{ validateAlternatives . }}	return errs
+}
+{{ end }}`
+
+	// Validate method generated for the holder of a value of an abstract
+	// type, checking the value by its own type
+	validateAbstract = `{{ define "ValidateAbstract" }}{{ $t := typeName .Type }}
+func (h {{ $t }}) validate(path string) []error {
+	if v, ok := h.Value.(interface{ validate(string) []error }); ok {
+		return v.validate(path)
+	}
+	return nil
+}
+{{ end }}`
+
+	// Validate methods generated for a simple type of its own, checking a
+	// value against its facets
+	validateSimpleType = `{{ define "ValidateSimpleType" }}{{ $t := typeName .Name }}
+// Validate checks v against the facets of its XSD type, and reports every
+// violation found
+func (v {{ $t }}) Validate() error {
+	return errors.Join(v.validate("")...)
+}
+
+func (v {{ $t }}) validate(path string) []error {
+	var errs []error
+{{ validateFacets . }}	return errs
+}
+{{ template "Patterns" . }}{{ end }}`
+
+	// Function generated for a flat simple type, checking a value of its
+	// built-in data type against its facets
+	validateFlat = `{{ define "ValidateFlat" }}
+// {{ validateFunc . }} checks v against the facets of the XSD type {{ .Name }}
+func {{ validateFunc . }}(v {{ .Base }}, path string) []error {
+	var errs []error
+{{ validateFacets . }}	return errs
+}
+{{ template "Patterns" . }}{{ end }}`
+
+	// Compiled patterns of a simple type, one per restriction
+	patterns = `{{ define "Patterns" }}{{ with patternVar . }}
+var {{ . }} = []*regexp.Regexp{
+{{ range $p := patternsOf $ }}	regexp.MustCompile({{ $p }}),
+{{ end }}}
+{{ end }}{{ end }}`
+)
+
+// validation generates the statements validating values against their XSD
+// types. The statements append the violations found to errs, located by the
+// path of the value.
+type validation struct {
+	typeName    func(string) string
+	choiceIface bool
+	optional    bool
+}
+
+// fields returns the statements validating the fields of v, a struct
+// generated from e.
+func (vd validation) fields(e *xmlTree) string {
+	var b strings.Builder
+	if e.Embed != nil {
+		b.WriteString(vd.embedded(e.Embed))
+	}
+	for _, a := range e.Attribs {
+		f, p := "v."+lintTitle(a.Name), "path+"+strconv.Quote("/@"+a.Name)
+		switch {
+		case a.Required && vd.optional:
+			var check string
+			if a.SimpleType != nil {
+				check = vd.simple("(*"+f+")", p, a.SimpleType)
+			}
+			b.WriteString(required(f, p, check))
+		case a.Required && a.SimpleType != nil:
+			b.WriteString(vd.simple(f, p, a.SimpleType))
+		case a.SimpleType == nil:
+		case vd.optional:
+			fmt.Fprintf(&b, "\tif %s != nil {\n%s\t}\n", f, indent(vd.simple("(*"+f+")", p, a.SimpleType)))
+		default:
+			fmt.Fprintf(&b, "\tif !(%s) {\n%s\t}\n", vd.zero(f, a.SimpleType), indent(vd.simple(f, p, a.SimpleType)))
+		}
+	}
+	for _, c := range e.Children {
+		switch {
+		case e.Sequence && !vd.choiceIface:
+			vd.field(&b, c, e.List || c.List, !(e.List || c.List))
+		case c.Choice && !vd.choiceIface:
+			for _, a := range c.Children {
+				if a.Sequence {
+					fmt.Fprintf(&b, "\terrs = append(errs, v.%s.validate(path)...)\n", vd.typeName(a.Type))
+					continue
+				}
+				vd.field(&b, a, c.List || a.List, !(c.List || a.List))
+			}
+		default:
+			vd.field(&b, c, c.List || (c.Choice && choiceList(c)), c.Recursive || c.Choice || ((vd.optional || c.Abstract) && c.Optional))
+		}
+	}
+	if e.Cdata && e.SimpleType != nil {
+		b.WriteString(vd.simple("v.Value", "path", e.SimpleType))
+	}
+	return b.String()
+}
+
+// embedded returns the statements validating the struct embedded in v,
+// generated from e.
+func (vd validation) embedded(e *xmlTree) string {
+	check := fmt.Sprintf("\terrs = append(errs, v.%s.validate(path)...)\n", vd.typeName(e.Type))
+	if e.Recursive {
+		return fmt.Sprintf("\tif v.%s != nil {\n%s\t}\n", vd.typeName(e.Type), indent(check))
+	}
+	return check
+}
+
+// field writes the statements validating a field of v generated from the
+// element e, a slice of list is set, or a pointer if ptr is set. An optional
+// element that is neither is only validated if it has a value other than the
+// zero value of its type.
+func (vd validation) field(b *strings.Builder, e *xmlTree, list, ptr bool) {
+	f := "v." + lintTitle(e.Name)
+	p := "path+" + strconv.Quote("/"+e.Name)
+	if e.Choice { // the alternatives are located by the choice
+		p = "path"
+	}
+
+	if list {
+		occurrences(b, f, p, e)
+	}
+
+	switch {
+	case list && e.Choice:
+		if check := vd.value("x", p, e); check != "" {
+			fmt.Fprintf(b, "\tfor _, x := range %s {\n%s\t}\n", f, indent(check))
+		}
+	case list:
+		if check := vd.value("x", "p", e); check != "" {
+			loc := strconv.Quote("%s/" + e.Name + "[%d]")
+			fmt.Fprintf(b, "\tfor i, x := range %s {\n\t\tp := fmt.Sprintf(%s, path, i+1)\n%s\t}\n", f, loc, indent(check))
+		}
+	case ptr:
+		if check := vd.value("(*"+f+")", p, e); check != "" {
+			fmt.Fprintf(b, "\tif %s != nil {\n%s\t}\n", f, indent(check))
+		}
+	case e.Optional:
+		if check := vd.value(f, p, e); check != "" {
+			zero := "reflect.ValueOf(" + f + ").IsZero()"
+			if e.SimpleType != nil && !e.Cdata {
+				zero = vd.zero(f, e.SimpleType)
+			}
+			fmt.Fprintf(b, "\tif !(%s) {\n%s\t}\n", zero, indent(check))
+		}
+	default:
+		b.WriteString(vd.value(f, p, e))
+	}
+}
+
+// zero returns the condition that x, a value of the simple type t, is the
+// zero value of its type, as an absent element or attribute decodes to.
+func (vd validation) zero(x string, t *xmlSimpleType) string {
+	if t.List {
+		return "len(" + x + ") == 0"
+	}
+	switch goKind(t.Base) {
+	case "string":
+		return x + ` == ""`
+	case "bool":
+		return "!" + x
+	case "int", "uint", "float":
+		return x + " == 0"
+	case "time":
+		return x + ".Time.IsZero()"
+	case "binary":
+		return "len(" + x + ") == 0"
+	}
+	typ := t.Base
+	if !t.Flat {
+		typ = vd.typeName(t.Name)
+	}
+	return fmt.Sprintf("%s == (%s{})", x, typ)
+}
+
+// items returns the statements validating v, a value of the list type t,
+// against the length facets of t, and every item of v against the facets of
+// the item type. Patterns of list types are left out.
+func (vd validation) items(t *xmlSimpleType) string {
+	var b strings.Builder
+	for _, f := range t.Facets {
+		for _, c := range []struct{ op, facet, value string }{
+			{"!=", "length", f.Length},
+			{"<", "minLength", f.MinLength},
+			{">", "maxLength", f.MaxLength},
+		} {
+			if n, err := strconv.Atoi(c.value); err == nil {
+				fmt.Fprintf(&b, "\tif len(v) %s %d {\n\t\terrs = append(errs, fmt.Errorf(\"%%s: %%d items violate %%s\", path, len(v), %s))\n\t}\n",
+					c.op, n, strconv.Quote(c.facet+" "+c.value))
+			}
+		}
+	}
+	if t.Item != nil {
+		fmt.Fprintf(&b, "\tfor _, x := range v {\n%s\t}\n", indent(vd.simple("x", "path", t.Item)))
+	}
+	return b.String()
+}
+
+// members returns the statements validating v, a value of the union type t,
+// as a value of any of its member types. The accessors of the members check
+// the value against their facets, so that a value of the first member it is
+// one of is valid.
+func (vd validation) members(t *xmlSimpleType) string {
+	var b strings.Builder
+	b.WriteString("\t")
+	for _, m := range unionMembers(vd.typeName, t) {
+		fmt.Fprintf(&b, "if _, ok := v.%s(); ok {\n\t} else ", m.Accessor)
+	}
+	b.WriteString("{\n\t\terrs = append(errs, fmt.Errorf(\"%s: %q violates %s\", path, string(v), \"memberTypes\"))\n\t}\n")
+	return b.String()
+}
+
+// occurrences writes the statements checking the number of occurrences of
+// e, held by the slice f, against its bounds.
+func occurrences(b *strings.Builder, f, p string, e *xmlTree) {
+	violation := func(cond, bound string) {
+		fmt.Fprintf(b, "\tif %s {\n\t\terrs = append(errs, fmt.Errorf(\"%%s: %%d occurrences violate %%s\", %s, len(%s), %s))\n\t}\n",
+			cond, p, f, strconv.Quote(bound))
+	}
+	if e.MinOccurs > 0 {
+		violation(fmt.Sprintf("len(%s) < %d", f, e.MinOccurs), "minOccurs "+strconv.Itoa(e.MinOccurs))
+	}
+	if e.MaxOccurs > 0 {
+		violation(fmt.Sprintf("len(%s) > %d", f, e.MaxOccurs), "maxOccurs "+strconv.Itoa(e.MaxOccurs))
+	}
+}
+
+// required returns the statements checking that the required attribute
+// held by the pointer field f is present, and if so, validating it by check.
+func required(f, p, check string) string {
+	missing := fmt.Sprintf("\tif %s == nil {\n\t\terrs = append(errs, fmt.Errorf(\"%%s: missing required attribute\", %s))\n\t}", f, p)
+	if check == "" {
+		return missing + "\n"
+	}
+	return fmt.Sprintf("%s else {\n%s\t}\n", missing, indent(check))
+}
+
+// value returns the statements validating x, a value of the type generated
+// from e, located by the path expression p.
+func (vd validation) value(x, p string, e *xmlTree) string {
+	switch {
+	case e.SimpleType != nil && !e.Cdata:
+		return vd.simple(x, p, e.SimpleType)
+	case e.Choice || !primitiveType(e):
+		return fmt.Sprintf("\terrs = append(errs, %s.validate(%s)...)\n", x, p)
+	}
+	return ""
+}
+
+// simple returns the statements validating x, a value of the simple type t,
+// located by the path expression p.
+func (vd validation) simple(x, p string, t *xmlSimpleType) string {
+	if t.Flat {
+		return fmt.Sprintf("\terrs = append(errs, %s(%s, %s)...)\n", vd.funcName(t), x, p)
+	}
+	return fmt.Sprintf("\terrs = append(errs, %s.validate(%s)...)\n", x, p)
+}
+
+// alternatives returns the statements validating the chosen alternative of
+// c, the holder of the choice e, if any of the alternatives has constraints.
+func (vd validation) alternatives(e *xmlTree) string {
+	var b strings.Builder
+	for _, a := range e.Children {
+		typ, p := vd.typeName(e.Type+lintTitle(a.Name)), "path+"+strconv.Quote("/"+a.Name)
+		if a.Sequence {
+			typ, p = vd.typeName(a.Type), "path"
+		}
+		v := "v." + vd.typeName(a.Type)
+		if a.Sequence {
+			v = vd.typeName(a.Type) + "(v)"
+		} else if simpleElement(a) {
+			v = "v.Value"
+		}
+		if check := vd.value(v, p, a); check != "" {
+			fmt.Fprintf(&b, "\tcase %s:\n%s", typ, indent(check))
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\tswitch v := c.Value.(type) {\n" + b.String() + "\t}\n"
+}
+
+// funcName returns the name of the function validating values of the flat
+// simple type t.
+func (vd validation) funcName(t *xmlSimpleType) string {
+	return "validate" + lintTitle(vd.typeName(t.Name))
+}
+
+// patternVar returns the name of the variable holding the patterns of t, or
+// nothing if t has no patterns.
+func (vd validation) patternVar(t *xmlSimpleType) string {
+	if len(patternsOf(t)) == 0 {
+		return ""
+	}
+	name := vd.typeName(t.Name)
+	return strings.ToLower(name[:1]) + name[1:] + "Patterns"
+}
+
+// facets returns the statements validating v, a value of the simple type t,
+// against its facets. Facets that do not apply to the Go type of the value,
+// or have values that are not valid for it, are left out.
+func (vd validation) facets(t *xmlSimpleType) string {
+	if t.List {
+		return vd.items(t)
+	}
+	if t.Union {
+		return vd.members(t)
+	}
+
+	var b strings.Builder
+	verb := "%v"
+	switch goKind(t.Base) {
+	case "string":
+		verb = "%q"
+	case "binary":
+		verb = "%x"
+	}
+	msg := strconv.Quote("%s: " + verb + " violates %s")
+	violation := func(cond, facet, value string) {
+		fmt.Fprintf(&b, "\tif %s {\n\t\terrs = append(errs, fmt.Errorf(%s, path, x, %s))\n\t}\n",
+			cond, msg, strconv.Quote(facet+" "+value))
+	}
+
+	if t.Values != nil && !t.Flat {
+		fmt.Fprintf(&b, "\tif !v.IsValid() {\n\t\terrs = append(errs, fmt.Errorf(%s, path, x, \"enumeration\"))\n\t}\n", msg)
+	}
+
+	pattern := 0
+	for _, f := range t.Facets {
+		switch kind := goKind(t.Base); kind {
+		case "string":
+			for _, c := range []struct{ op, facet, value string }{
+				{"!=", "length", f.Length},
+				{"<", "minLength", f.MinLength},
+				{">", "maxLength", f.MaxLength},
+			} {
+				if n, err := strconv.Atoi(c.value); err == nil {
+					violation(fmt.Sprintf("utf8.RuneCountInString(x) %s %d", c.op, n), c.facet, c.value)
+				}
+			}
+			if _, ok := xsdRegexp(f.Patterns); ok {
+				violation(fmt.Sprintf("!%s[%d].MatchString(x)", vd.patternVar(t), pattern), "pattern", strings.Join(f.Patterns, " | "))
+				pattern++
+			}
+		case "binary":
+			for _, c := range []struct{ op, facet, value string }{
+				{"!=", "length", f.Length},
+				{"<", "minLength", f.MinLength},
+				{">", "maxLength", f.MaxLength},
+			} {
+				if n, err := strconv.Atoi(c.value); err == nil {
+					violation(fmt.Sprintf("len(x) %s %d", c.op, n), c.facet, c.value)
+				}
+			}
+		case "int", "uint", "float":
+			for _, c := range []struct{ op, facet, value string }{
+				{"<", "minInclusive", f.MinInclusive},
+				{">", "maxInclusive", f.MaxInclusive},
+				{"<=", "minExclusive", f.MinExclusive},
+				{">=", "maxExclusive", f.MaxExclusive},
+			} {
+				if lit, ok := enumLiteral(t.Base, c.value); ok && c.value != "" {
+					violation(fmt.Sprintf("x %s %s", c.op, lit), c.facet, c.value)
+				}
+			}
+			format := fmt.Sprintf("strconv.FormatFloat(x, 'f', -1, %d)", bitSize(t.Base))
+			if t.Base != "float64" {
+				format = fmt.Sprintf("strconv.FormatFloat(float64(x), 'f', -1, %d)", bitSize(t.Base))
+			}
+			if n, err := strconv.Atoi(f.TotalDigits); err == nil {
+				digits := `len(strings.TrimLeft(strings.Replace(strings.TrimLeft(` + format + `, "-"), ".", "", 1), "0"))`
+				switch kind {
+				case "int":
+					digits = `len(strings.TrimLeft(strconv.FormatInt(int64(x), 10), "-"))`
+				case "uint":
+					digits = `len(strconv.FormatUint(uint64(x), 10))`
+				}
+				violation(fmt.Sprintf("%s > %d", digits, n), "totalDigits", f.TotalDigits)
+			}
+			if n, err := strconv.Atoi(f.FractionDigits); err == nil && kind == "float" {
+				violation(fmt.Sprintf(`s := %s; strings.Contains(s, ".") && len(s)-strings.Index(s, ".")-1 > %d`, format, n), "fractionDigits", f.FractionDigits)
+			}
+		case "decimal", "integer":
+			parse, literal := "xsdtype.MustParseDecimal", decimalLiteral
+			if kind == "integer" {
+				parse, literal = "xsdtype.MustParseInteger", integerLiteral
+			}
+			for _, c := range []struct{ op, facet, value string }{
+				{"<", "minInclusive", f.MinInclusive},
+				{">", "maxInclusive", f.MaxInclusive},
+				{"<=", "minExclusive", f.MinExclusive},
+				{">=", "maxExclusive", f.MaxExclusive},
+			} {
+				if v := strings.TrimSpace(c.value); literal.MatchString(v) {
+					violation(fmt.Sprintf("x.Cmp(%s(%q)) %s 0", parse, v, c.op), c.facet, c.value)
+				}
+			}
+			if n, err := strconv.Atoi(f.TotalDigits); err == nil {
+				violation(fmt.Sprintf("x.TotalDigits() > %d", n), "totalDigits", f.TotalDigits)
+			}
+			if n, err := strconv.Atoi(f.FractionDigits); err == nil && kind == "decimal" {
+				violation(fmt.Sprintf("x.FractionDigits() > %d", n), "fractionDigits", f.FractionDigits)
+			}
+		}
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	x := fmt.Sprintf("\tx := %s(v)\n", t.Base)
+	if t.Base == "string" {
+		switch t.WhiteSpace {
+		case "replace":
+			x += "\tx = strings.Map(func(r rune) rune {\n\t\tif r == '\\t' || r == '\\n' || r == '\\r' {\n\t\t\treturn ' '\n\t\t}\n\t\treturn r\n\t}, x)\n"
+		case "collapse":
+			x += "\tx = strings.Join(strings.Fields(x), \" \")\n"
+		}
+	}
+	return x + b.String()
+}
+
+// patternsOf returns the Go string literals of the regular expressions of
+// the patterns of t, one per restriction with patterns that translate into
+// valid Go regular expressions.
+func patternsOf(t *xmlSimpleType) []string {
+	if t.Base != "string" {
+		return nil
+	}
+	var res []string
+	for _, f := range t.Facets {
+		if re, ok := xsdRegexp(f.Patterns); ok {
+			res = append(res, "`"+re+"`")
+		}
+	}
+	return res
+}
+
+// Literals of the decimal and integer data types, in facets.
+var (
+	decimalLiteral = regexp.MustCompile(`^[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)$`)
+	integerLiteral = regexp.MustCompile(`^[+-]?[0-9]+$`)
+)
+
+// xsdRegexp translates the patterns of a restriction, of which a value must
+// match any, into a single Go regular expression matching whole values. It
+// reports false if there are no patterns, or if any of them cannot be
+// translated.
+func xsdRegexp(patterns []string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+
+	var alts []string
+	for _, p := range patterns {
+		re, ok := translateRegexp(p)
+		if !ok {
+			return "", false
+		}
+		alts = append(alts, "(?:"+re+")")
+	}
+	re := "^(?:" + strings.Join(alts, "|") + ")$"
+	if _, err := regexp.Compile(re); err != nil || strings.Contains(re, "`") {
+		return "", false
+	}
+	return re, true
+}
+
+// translateRegexp translates an XSD regular expression into Go syntax. XSD
+// has no anchors, so ^ and $ match themselves, and the name character escapes
+// \i and \c are spelled out. Character class subtraction is not supported.
+func translateRegexp(p string) (string, bool) {
+	var b strings.Builder
+	class := false
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '\\' && i+1 < len(p):
+			i++
+			switch e := p[i]; {
+			case e == 'i' && class:
+				b.WriteString(`_:\p{L}`)
+			case e == 'c' && class:
+				b.WriteString(`\-._:\p{L}\p{N}`)
+			case e == 'i':
+				b.WriteString(`[_:\p{L}]`)
+			case e == 'c':
+				b.WriteString(`[\-._:\p{L}\p{N}]`)
+			case e == 'I' && !class:
+				b.WriteString(`[^_:\p{L}]`)
+			case e == 'C' && !class:
+				b.WriteString(`[^\-._:\p{L}\p{N}]`)
+			case e == 'I' || e == 'C':
+				return "", false
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		case c == '[' && class:
+			return "", false // subtraction
+		case c == '[':
+			class = true
+			b.WriteByte(c)
+		case c == ']' && class:
+			class = false
+			b.WriteByte(c)
+		case (c == '^' || c == '$') && !class:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+// indent indents generated statements by another tab.
+func indent(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "\t" + strings.Replace(strings.TrimSuffix(s, "\n"), "\n", "\n\t", -1) + "\n"
+}
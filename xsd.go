@@ -6,23 +6,74 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/text/encoding/charmap"
 )
 
-var (
-	parsedFiles = make(map[string]struct{})
-)
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// schemaLoader loads schema documents, following their imports, includes,
+// redefines and overrides. It keeps track of the documents loaded, and is
+// meant to be used for a single generation run.
+type schemaLoader struct {
+	// loaded holds the loaded documents, with the name of the document
+	// that first referred to each, or its own name if it was loaded first.
+	loaded map[schemaDoc]string
+}
+
+// schemaDoc identifies a loaded schema document. A document without a target
+// namespace takes on the namespace of the schema including it, so the same
+// file may be loaded once per namespace.
+type schemaDoc struct {
+	path      string
+	namespace string
+}
+
+func newSchemaLoader() *schemaLoader {
+	return &schemaLoader{loaded: make(map[schemaDoc]string)}
+}
 
+// parseXSDFile loads the schema document in the given file, using a new
+// schemaLoader.
 func parseXSDFile(fname string) ([]xsdSchema, error) {
+	return newSchemaLoader().loadFile(fname, "", "")
+}
+
+// parse loads a schema document from r, using a new schemaLoader. Any
+// documents it refers to are looked up relative to fname.
+func parse(r io.Reader, fname string) ([]xsdSchema, error) {
+	return newSchemaLoader().load(r, fname, "", "")
+}
+
+func (l *schemaLoader) loadFile(fname, ns, from string) ([]xsdSchema, error) {
 	f, err := os.Open(fname)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	return parse(f, fname)
+	return l.load(f, fname, ns, from)
+}
+
+// referrer returns the name of the document that first referred to the
+// loaded document in the given file, preferably as loaded into the namespace
+// ns.
+func (l *schemaLoader) referrer(fname, ns string) string {
+	path, _ := filepath.Abs(fname)
+	if from, ok := l.loaded[schemaDoc{path: path, namespace: ns}]; ok {
+		return from
+	}
+	var froms []string
+	for doc, from := range l.loaded {
+		if doc.path == path {
+			froms = append(froms, from)
+		}
+	}
+	sort.Strings(froms)
+	return strings.Join(froms, ", ")
 }
 
 // makeCharsetReader returns special readers as needed for xml encodings, or
@@ -34,24 +85,89 @@ func makeCharsetReader(charset string, input io.Reader) (io.Reader, error) {
 	return nil, fmt.Errorf("Unknown charset: %s", charset)
 }
 
-func parse(r io.Reader, fname string) ([]xsdSchema, error) {
+// load decodes a schema document, and returns it along with the documents it
+// imports. Included, redefined and overridden documents are merged into the
+// schema. The namespace ns is taken on by a document without a target
+// namespace of its own. The document is referred to by the document from,
+// if any.
+//
+// A document that has already been loaded results in no schemas. This also
+// breaks import cycles, as a document is regarded as loaded as soon as its
+// loading starts. Its components cannot be redefined or overridden then, as
+// they are already in use as they are.
+func (l *schemaLoader) load(r io.Reader, fname, ns, from string) ([]xsdSchema, error) {
 	var schema xsdSchema
 
 	d := xml.NewDecoder(r)
 	// handle special character sets
 	d.CharsetReader = makeCharsetReader
 	if err := d.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("%s: %s", fname, err)
+	}
+	chameleon := schema.TargetNamespace == ""
+	if chameleon {
+		schema.TargetNamespace = ns
+	}
+	schema.qualify(fname, chameleon)
+
+	path, err := filepath.Abs(fname)
+	if err != nil {
 		return nil, err
 	}
+	doc := schemaDoc{path: path, namespace: schema.TargetNamespace}
+	if _, ok := l.loaded[doc]; ok {
+		return nil, nil
+	}
+	if from == "" {
+		from = fname
+	}
+	l.loaded[doc] = from
 
-	schemas := []xsdSchema{schema}
-	dir, file := filepath.Split(fname)
-	parsedFiles[file] = struct{}{}
-	for _, imp := range schema.Imports {
-		if _, ok := parsedFiles[imp.Location]; ok {
-			continue
+	dir := filepath.Dir(fname)
+	var imported []xsdSchema
+	merge := func(location string, r *xsdRedefine, override bool) error {
+		s, err := l.loadFile(filepath.Join(dir, location), schema.TargetNamespace, fname)
+		if err != nil {
+			return err
 		}
-		s, err := parseXSDFile(filepath.Join(dir, imp.Location))
+		if len(s) == 0 {
+			if r == nil {
+				return nil
+			}
+			kind := "redefine"
+			if override {
+				kind = "override"
+			}
+			return fmt.Errorf("%s: cannot %s %s, already loaded through %s",
+				fname, kind, location, l.referrer(filepath.Join(dir, location), schema.TargetNamespace))
+		}
+		if r != nil {
+			s[0].redefine(*r, override)
+		}
+		schema.merge(s[0])
+		imported = append(imported, s[1:]...)
+		return nil
+	}
+
+	for _, inc := range schema.Includes {
+		if err := merge(inc.Location, nil, false); err != nil {
+			return nil, err
+		}
+	}
+	for i := range schema.Redefines {
+		if err := merge(schema.Redefines[i].Location, &schema.Redefines[i], false); err != nil {
+			return nil, err
+		}
+	}
+	for i := range schema.Overrides {
+		if err := merge(schema.Overrides[i].Location, &schema.Overrides[i], true); err != nil {
+			return nil, err
+		}
+	}
+
+	schemas := append([]xsdSchema{schema}, imported...)
+	for _, imp := range schema.Imports {
+		s, err := l.loadFile(filepath.Join(dir, imp.Location), "", fname)
 		if err != nil {
 			return nil, err
 		}
@@ -60,33 +176,422 @@ func parse(r io.Reader, fname string) ([]xsdSchema, error) {
 	return schemas, nil
 }
 
+// qualify resolves the QName references within the schema, by the namespace
+// prefixes it declares, and writes them as {namespace}local. References
+// without a namespace are left unprefixed, and when chameleon is set, as for
+// an included schema without a target namespace of its own, they are taken
+// to be in the target namespace. Elements and attributes are stamped with
+// the target namespace of the schema declaring them, and whether their names
+// are qualified by it in instance documents. Components are stamped with the
+// name of the schema file, for error reporting.
+//
+// Once qualified, the components of a schema can be moved into another
+// schema, or be resolved across schemas, without regard to the prefixes in
+// scope where they were declared.
+func (s *xsdSchema) qualify(fname string, chameleon bool) {
+	q := qualifier{
+		file:          fname,
+		prefixes:      s.prefixes(),
+		namespace:     s.TargetNamespace,
+		chameleon:     chameleon,
+		elemQualified: s.ElementFormDefault == "qualified",
+		attrQualified: s.AttributeFormDefault == "qualified",
+	}
+
+	for i := range s.Elements {
+		q.element(&s.Elements[i], true)
+	}
+	q.attributes(s.Attributes, true)
+	for i := range s.ComplexTypes {
+		q.complexType(&s.ComplexTypes[i])
+	}
+	for i := range s.SimpleTypes {
+		q.simpleType(&s.SimpleTypes[i])
+	}
+	for i := range s.Groups {
+		q.group(&s.Groups[i])
+	}
+	q.attributeGroups(s.AttributeGroups)
+
+	redefines := append(append([]xsdRedefine{}, s.Redefines...), s.Overrides...)
+	for _, r := range redefines {
+		for i := range r.ComplexTypes {
+			q.complexType(&r.ComplexTypes[i])
+		}
+		for i := range r.SimpleTypes {
+			q.simpleType(&r.SimpleTypes[i])
+		}
+		for i := range r.Groups {
+			q.group(&r.Groups[i])
+		}
+		q.attributeGroups(r.AttributeGroups)
+	}
+}
+
+// qualifier resolves QName references within a single schema document.
+type qualifier struct {
+	file          string
+	prefixes      map[string]string
+	namespace     string
+	chameleon     bool
+	elemQualified bool // elementFormDefault="qualified"
+	attrQualified bool // attributeFormDefault="qualified"
+}
+
+// qname returns the reference with its prefix resolved to a namespace. A
+// prefix that is not declared is left as it is.
+func (q qualifier) qname(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	prefix, local := "", ref
+	if i := strings.Index(ref, ":"); i >= 0 {
+		prefix, local = ref[:i], ref[i+1:]
+	}
+	ns, ok := q.prefixes[prefix]
+	if !ok && prefix != "" {
+		return ref
+	}
+	if ns == "" && q.chameleon {
+		ns = q.namespace
+	}
+	if ns == "" {
+		return local
+	}
+	return "{" + ns + "}" + local
+}
+
+// typeRef returns the type reference with its prefix resolved, like qname.
+// A schema not declaring the XSD namespace at all can only mean the built-in
+// types by their unprefixed names, which are resolved to that namespace.
+func (q qualifier) typeRef(ref string) string {
+	if !strings.Contains(ref, ":") && isBuiltinType(ref) {
+		declared := false
+		for _, ns := range q.prefixes {
+			declared = declared || ns == xsdNamespace
+		}
+		if !declared {
+			return "{" + xsdNamespace + "}" + ref
+		}
+	}
+	return q.qname(ref)
+}
+
+// element qualifies a global or a local element declaration. A global
+// element is always qualified, while a local one is qualified by its form,
+// or the default form of the schema.
+func (q qualifier) element(e *xsdElement, global bool) {
+	q.stamp(&e.xsdPos)
+	e.Namespace = q.namespace
+	e.Qualified = q.namespace != "" && (global || qualifiedForm(e.Form, q.elemQualified))
+	e.Ref = q.qname(e.Ref)
+	e.Type = q.typeRef(e.Type)
+	if e.ComplexType != nil {
+		q.complexType(e.ComplexType)
+	}
+	if e.SimpleType != nil {
+		q.simpleType(e.SimpleType)
+	}
+}
+
+func (q qualifier) complexType(t *xsdComplexType) {
+	q.stamp(&t.xsdPos)
+	q.contentModel(&t.xsdContentModel)
+	q.attributes(t.Attributes, false)
+	q.attributeGroups(t.AttributeGroups)
+	if c := t.ComplexContent; c != nil {
+		q.derivation(c.Extension, c.Restriction)
+	}
+	if c := t.SimpleContent; c != nil {
+		q.derivation(c.Extension, c.Restriction)
+	}
+}
+
+func (q qualifier) derivation(e *xsdExtension, r *xsdRestriction) {
+	if e != nil {
+		q.stamp(&e.xsdPos)
+		e.Base = q.typeRef(e.Base)
+		q.contentModel(&e.xsdContentModel)
+		q.attributes(e.Attributes, false)
+		q.attributeGroups(e.AttributeGroups)
+	}
+	if r != nil {
+		q.stamp(&r.xsdPos)
+		r.Base = q.typeRef(r.Base)
+		q.contentModel(&r.xsdContentModel)
+		q.attributes(r.Attributes, false)
+		q.attributeGroups(r.AttributeGroups)
+	}
+}
+
+func (q qualifier) simpleType(t *xsdSimpleType) {
+	q.stamp(&t.xsdPos)
+	q.derivation(nil, &t.Restriction)
+	if l := t.List; l != nil {
+		l.ItemType = q.typeRef(l.ItemType)
+		if l.SimpleType != nil {
+			q.simpleType(l.SimpleType)
+		}
+	}
+	if u := t.Union; u != nil {
+		members := strings.Fields(u.MemberTypes)
+		for i, m := range members {
+			members[i] = q.typeRef(m)
+		}
+		u.MemberTypes = strings.Join(members, " ")
+		for i := range u.SimpleTypes {
+			q.simpleType(&u.SimpleTypes[i])
+		}
+	}
+}
+
+func (q qualifier) contentModel(c *xsdContentModel) {
+	for _, g := range []*xsdModelGroup{c.Sequence, c.Choice, c.All} {
+		if g != nil {
+			q.modelGroup(g)
+		}
+	}
+	if c.Group != nil {
+		q.group(c.Group)
+	}
+}
+
+// group qualifies a named group definition, or a reference to one.
+func (q qualifier) group(g *xsdGroup) {
+	q.stamp(&g.xsdPos)
+	g.Ref = q.qname(g.Ref)
+	q.contentModel(&g.xsdContentModel)
+}
+
+func (q qualifier) modelGroup(g *xsdModelGroup) {
+	q.stamp(&g.xsdPos)
+	for _, p := range g.Particles {
+		switch {
+		case p.Element != nil:
+			q.element(p.Element, false)
+		case p.Group != nil:
+			q.modelGroup(p.Group)
+		case p.GroupRef != nil:
+			q.group(p.GroupRef)
+		}
+	}
+}
+
+// attributes qualifies global or local attribute declarations, like element.
+func (q qualifier) attributes(attrs []xsdAttribute, global bool) {
+	for i := range attrs {
+		q.stamp(&attrs[i].xsdPos)
+		attrs[i].Namespace = q.namespace
+		attrs[i].Qualified = q.namespace != "" && (global || qualifiedForm(attrs[i].Form, q.attrQualified))
+		attrs[i].Ref = q.qname(attrs[i].Ref)
+		attrs[i].Type = q.typeRef(attrs[i].Type)
+		if attrs[i].SimpleType != nil {
+			q.simpleType(attrs[i].SimpleType)
+		}
+	}
+}
+
+// stamp records the file and the target namespace of the schema document in
+// the position of a component.
+func (q qualifier) stamp(pos *xsdPos) {
+	pos.File, pos.TargetNamespace = q.file, q.namespace
+}
+
+func qualifiedForm(form string, qualifiedDefault bool) bool {
+	if form == "" {
+		return qualifiedDefault
+	}
+	return form == "qualified"
+}
+
+func (q qualifier) attributeGroups(groups []xsdAttributeGroup) {
+	for i := range groups {
+		q.stamp(&groups[i].xsdPos)
+		groups[i].Ref = q.qname(groups[i].Ref)
+		q.attributes(groups[i].Attributes, false)
+		q.attributeGroups(groups[i].AttributeGroups)
+	}
+}
+
 // xsdSchema is the root of our Go representation of an XSD schema.
 type xsdSchema struct {
-	XMLName      xml.Name
-	Ns           string           `xml:"xmlns,attr"`
-	Imports      []xsdImport      `xml:"import"`
-	Elements     []xsdElement     `xml:"element"`
-	ComplexTypes []xsdComplexType `xml:"complexType"`
-	SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+	XMLName              xml.Name
+	TargetNamespace      string              `xml:"targetNamespace,attr"`
+	ElementFormDefault   string              `xml:"elementFormDefault,attr"`
+	AttributeFormDefault string              `xml:"attributeFormDefault,attr"`
+	Attrs                []xml.Attr          `xml:",any,attr"`
+	Imports              []xsdImport         `xml:"import"`
+	Includes             []xsdImport         `xml:"include"`
+	Redefines            []xsdRedefine       `xml:"redefine"`
+	Overrides            []xsdRedefine       `xml:"override"`
+	Elements             []xsdElement        `xml:"element"`
+	Attributes           []xsdAttribute      `xml:"attribute"`
+	ComplexTypes         []xsdComplexType    `xml:"complexType"`
+	SimpleTypes          []xsdSimpleType     `xml:"simpleType"`
+	Groups               []xsdGroup          `xml:"group"`
+	AttributeGroups      []xsdAttributeGroup `xml:"attributeGroup"`
+}
+
+// prefixes returns the namespace prefixes declared by the schema, with the
+// default namespace mapped by the empty prefix.
+func (s xsdSchema) prefixes() map[string]string {
+	prefixes := make(map[string]string)
+	for _, a := range s.Attrs {
+		switch {
+		case a.Name.Space == "xmlns":
+			prefixes[a.Name.Local] = a.Value
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			prefixes[""] = a.Value
+		}
+	}
+	return prefixes
+}
+
+// merge adds the components of an included schema to s.
+func (s *xsdSchema) merge(inc xsdSchema) {
+	s.Elements = append(s.Elements, inc.Elements...)
+	s.Attributes = append(s.Attributes, inc.Attributes...)
+	s.ComplexTypes = append(s.ComplexTypes, inc.ComplexTypes...)
+	s.SimpleTypes = append(s.SimpleTypes, inc.SimpleTypes...)
+	s.Groups = append(s.Groups, inc.Groups...)
+	s.AttributeGroups = append(s.AttributeGroups, inc.AttributeGroups...)
 }
 
-// ns parses the namespace from a value in the expected format
-// http://host/namespace/v1
-func (s xsdSchema) ns() string {
-	split := strings.Split(s.Ns, "/")
-	if len(split) > 2 {
-		return split[len(split)-2]
+// redefine replaces components of s with those given by an xs:redefine or
+// an xs:override. An overridden component is simply dropped. A redefined
+// component is kept under a new name, as the redefinition refers to it by
+// its own name, and those references are changed to the new name.
+func (s *xsdSchema) redefine(r xsdRedefine, override bool) {
+	for _, t := range r.ComplexTypes {
+		for i, o := range s.ComplexTypes {
+			if o.Name != t.Name {
+				continue
+			}
+			if override {
+				s.ComplexTypes = append(s.ComplexTypes[:i], s.ComplexTypes[i+1:]...)
+				break
+			}
+			s.ComplexTypes[i].Name = o.Name + "Original"
+			if c := t.ComplexContent; c != nil {
+				renameBase(c.Extension, c.Restriction, o.Name)
+			}
+			if c := t.SimpleContent; c != nil {
+				renameBase(c.Extension, c.Restriction, o.Name)
+			}
+			break
+		}
+		s.ComplexTypes = append(s.ComplexTypes, t)
+	}
+
+	for _, t := range r.SimpleTypes {
+		for i, o := range s.SimpleTypes {
+			if o.Name != t.Name {
+				continue
+			}
+			if override {
+				s.SimpleTypes = append(s.SimpleTypes[:i], s.SimpleTypes[i+1:]...)
+				break
+			}
+			s.SimpleTypes[i].Name = o.Name + "Original"
+			renameBase(nil, &t.Restriction, o.Name)
+			break
+		}
+		s.SimpleTypes = append(s.SimpleTypes, t)
+	}
+
+	for _, g := range r.Groups {
+		for i, o := range s.Groups {
+			if o.Name != g.Name {
+				continue
+			}
+			if override {
+				s.Groups = append(s.Groups[:i], s.Groups[i+1:]...)
+				break
+			}
+			s.Groups[i].Name = o.Name + "Original"
+			if mg := g.modelGroup(); mg != nil {
+				renameGroupRefs(mg, o.Name)
+			}
+			break
+		}
+		s.Groups = append(s.Groups, g)
+	}
+
+	for _, g := range r.AttributeGroups {
+		for i, o := range s.AttributeGroups {
+			if o.Name != g.Name {
+				continue
+			}
+			if override {
+				s.AttributeGroups = append(s.AttributeGroups[:i], s.AttributeGroups[i+1:]...)
+				break
+			}
+			s.AttributeGroups[i].Name = o.Name + "Original"
+			for j, ref := range g.AttributeGroups {
+				g.AttributeGroups[j].Ref = renameRef(ref.Ref, o.Name)
+			}
+			break
+		}
+		s.AttributeGroups = append(s.AttributeGroups, g)
 	}
-	return ""
 }
 
+// renameBase changes the base of a redefining extension or restriction, if
+// it refers to the redefined component.
+func renameBase(e *xsdExtension, r *xsdRestriction, name string) {
+	if e != nil {
+		e.Base = renameRef(e.Base, name)
+	}
+	if r != nil {
+		r.Base = renameRef(r.Base, name)
+	}
+}
+
+// renameGroupRefs changes the group references within a redefining model
+// group, that refer to the redefined group.
+func renameGroupRefs(g *xsdModelGroup, name string) {
+	for _, p := range g.Particles {
+		switch {
+		case p.Group != nil:
+			renameGroupRefs(p.Group, name)
+		case p.GroupRef != nil:
+			p.GroupRef.Ref = renameRef(p.GroupRef.Ref, name)
+		}
+	}
+}
+
+// renameRef returns the name of the original of a redefined component, if
+// ref refers to that component, keeping any namespace prefix.
+func renameRef(ref, name string) string {
+	if stripNamespace(ref) != name {
+		return ref
+	}
+	return ref + "Original"
+}
+
+// xsdImport is an xs:import or an xs:include of another schema document.
 type xsdImport struct {
 	Location string `xml:"schemaLocation,attr"`
 }
 
+// xsdRedefine is an xs:redefine or an xs:override of components in another
+// schema document.
+type xsdRedefine struct {
+	Location        string              `xml:"schemaLocation,attr"`
+	ComplexTypes    []xsdComplexType    `xml:"complexType"`
+	SimpleTypes     []xsdSimpleType     `xml:"simpleType"`
+	Groups          []xsdGroup          `xml:"group"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+}
+
 type xsdElement struct {
+	xsdPos
 	Name        string          `xml:"name,attr"`
+	Ref         string          `xml:"ref,attr"`
 	Type        string          `xml:"type,attr"`
+	Form        string          `xml:"form,attr"`
+	Namespace   string          `xml:"-"` // target namespace of the declaring schema
+	Qualified   bool            `xml:"-"` // name is qualified by Namespace
 	Default     string          `xml:"default,attr"`
 	Min         string          `xml:"minOccurs,attr"`
 	Max         string          `xml:"maxOccurs,attr"`
@@ -96,7 +601,8 @@ type xsdElement struct {
 }
 
 func (e xsdElement) isList() bool {
-	return e.Max == "unbounded"
+	_, max := occurs(e.Min, e.Max)
+	return max < 0 || max > 1
 }
 
 func (e xsdElement) inlineType() bool {
@@ -104,13 +610,21 @@ func (e xsdElement) inlineType() bool {
 }
 
 type xsdComplexType struct {
-	Name           string             `xml:"name,attr"`
-	Abstract       string             `xml:"abstract,attr"`
-	Annotation     string             `xml:"annotation>documentation"`
-	Sequence       []xsdElement       `xml:"sequence>element"`
-	Attributes     []xsdAttribute     `xml:"attribute"`
-	ComplexContent *xsdComplexContent `xml:"complexContent"`
-	SimpleContent  *xsdSimpleContent  `xml:"simpleContent"`
+	xsdPos
+	Name       string `xml:"name,attr"`
+	Abstract   string `xml:"abstract,attr"`
+	Annotation string `xml:"annotation>documentation"`
+	xsdContentModel
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	ComplexContent  *xsdComplexContent  `xml:"complexContent"`
+	SimpleContent   *xsdSimpleContent   `xml:"simpleContent"`
+}
+
+// isAbstract reports whether t is abstract, so that elements of the type must
+// be of a type derived from it, named by xsi:type.
+func (t xsdComplexType) isAbstract() bool {
+	return t.Abstract == "true" || t.Abstract == "1"
 }
 
 type xsdComplexContent struct {
@@ -124,28 +638,216 @@ type xsdSimpleContent struct {
 }
 
 type xsdExtension struct {
-	Base       string         `xml:"base,attr"`
-	Attributes []xsdAttribute `xml:"attribute"`
-	Sequence   []xsdElement   `xml:"sequence>element"`
+	xsdPos
+	Base            string              `xml:"base,attr"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	xsdContentModel
+}
+
+func (e xsdExtension) hasAttributes() bool {
+	return e.Attributes != nil || e.AttributeGroups != nil
+}
+
+// xsdContentModel is the top level model group of a complex type, an
+// extension or a named group. At most one of its fields is set.
+type xsdContentModel struct {
+	Sequence *xsdModelGroup `xml:"sequence"`
+	Choice   *xsdModelGroup `xml:"choice"`
+	All      *xsdModelGroup `xml:"all"`
+	Group    *xsdGroup      `xml:"group"` // reference to a named group
+}
+
+// modelGroup returns the top level model group, or nil if there is none. A
+// group reference is returned wrapped in a sequence.
+func (c xsdContentModel) modelGroup() *xsdModelGroup {
+	switch {
+	case c.Sequence != nil:
+		return c.Sequence
+	case c.Choice != nil:
+		return c.Choice
+	case c.All != nil:
+		return c.All
+	case c.Group != nil:
+		return &xsdModelGroup{
+			Kind:      "sequence",
+			Particles: []xsdParticle{{GroupRef: c.Group}},
+		}
+	}
+	return nil
+}
+
+// xsdGroup is a named model group definition, or a reference to one.
+type xsdGroup struct {
+	xsdPos
+	Name string `xml:"name,attr"`
+	Ref  string `xml:"ref,attr"`
+	Min  string `xml:"minOccurs,attr"`
+	Max  string `xml:"maxOccurs,attr"`
+	xsdContentModel
+}
+
+// xsdModelGroup is a sequence, choice or all compositor. The particles are kept
+// in document order, which is why it is decoded by hand rather than by
+// struct tags.
+type xsdModelGroup struct {
+	xsdPos
+	Kind      string // "sequence", "choice" or "all"
+	Min       string
+	Max       string
+	Particles []xsdParticle
+}
+
+// xsdParticle is a single term of a model group; exactly one of its fields
+// is set.
+type xsdParticle struct {
+	Element  *xsdElement
+	Group    *xsdModelGroup // nested sequence, choice or all
+	GroupRef *xsdGroup      // reference to a named group
+}
+
+func (g xsdModelGroup) isList() bool {
+	_, max := occurs(g.Min, g.Max)
+	return max < 0 || max > 1
+}
+
+// occurs returns the occurrence bounds given by the minOccurs and maxOccurs
+// attributes min and max of a particle, with max -1 if unbounded. Both bounds
+// default to one.
+func occurs(min, max string) (int, int) {
+	lo, hi := 1, 1
+	if n, err := strconv.Atoi(min); err == nil && n >= 0 {
+		lo = n
+	}
+	if max == "unbounded" {
+		hi = -1
+	} else if n, err := strconv.Atoi(max); err == nil && n >= 0 {
+		hi = n
+	}
+	return lo, hi
+}
+
+func (g *xsdModelGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	g.Line, g.Col = d.InputPos()
+	g.Kind = start.Name.Local
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "minOccurs":
+			g.Min = a.Value
+		case "maxOccurs":
+			g.Max = a.Value
+		}
+	}
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "element":
+				var e xsdElement
+				if err := d.DecodeElement(&e, &t); err != nil {
+					return err
+				}
+				g.Particles = append(g.Particles, xsdParticle{Element: &e})
+			case "sequence", "choice", "all":
+				var n xsdModelGroup
+				if err := d.DecodeElement(&n, &t); err != nil {
+					return err
+				}
+				g.Particles = append(g.Particles, xsdParticle{Group: &n})
+			case "group":
+				var r xsdGroup
+				if err := d.DecodeElement(&r, &t); err != nil {
+					return err
+				}
+				g.Particles = append(g.Particles, xsdParticle{GroupRef: &r})
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
 }
 
 type xsdAttribute struct {
-	Name       string `xml:"name,attr"`
-	Type       string `xml:"type,attr"`
-	Use        string `xml:"use,attr"`
-	Annotation string `xml:"annotation>documentation"`
+	xsdPos
+	Name       string         `xml:"name,attr"`
+	Ref        string         `xml:"ref,attr"`
+	Type       string         `xml:"type,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"` // inline simple type
+	Use        string         `xml:"use,attr"`
+	Annotation string         `xml:"annotation>documentation"`
+	Form       string         `xml:"form,attr"`
+	Namespace  string         `xml:"-"` // target namespace of the declaring schema
+	Qualified  bool           `xml:"-"` // name is qualified by Namespace
+}
+
+// xsdAttributeGroup is a named attribute group definition, or a reference to
+// one.
+type xsdAttributeGroup struct {
+	xsdPos
+	Name            string              `xml:"name,attr"`
+	Ref             string              `xml:"ref,attr"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
 }
 
 type xsdSimpleType struct {
+	xsdPos
 	Name        string         `xml:"name,attr"`
 	Annotation  string         `xml:"annotation>documentation"`
 	Restriction xsdRestriction `xml:"restriction"`
+	List        *xsdList       `xml:"list"`
+	Union       *xsdUnion      `xml:"union"`
 }
 
+// xsdList derives a list type, whose values are whitespace separated lists of
+// values of its item type, named or inline.
+type xsdList struct {
+	ItemType   string         `xml:"itemType,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"`
+}
+
+// xsdUnion derives a union type, whose values are values of any of its member
+// types, named or inline. The named member types come first.
+type xsdUnion struct {
+	MemberTypes string          `xml:"memberTypes,attr"`
+	SimpleTypes []xsdSimpleType `xml:"simpleType"`
+}
+
+// xsdRestriction restricts a simple type by its facets, or a complex type by
+// the particles and attributes it declares.
 type xsdRestriction struct {
-	Base        string           `xml:"base,attr"`
-	Pattern     xsdPattern       `xml:"pattern"`
-	Enumeration []xsdEnumeration `xml:"enumeration"`
+	xsdPos
+	Base           string           `xml:"base,attr"`
+	Patterns       []xsdPattern     `xml:"pattern"`
+	Enumeration    []xsdEnumeration `xml:"enumeration"`
+	Length         *xsdFacet        `xml:"length"`
+	MinLength      *xsdFacet        `xml:"minLength"`
+	MaxLength      *xsdFacet        `xml:"maxLength"`
+	MinInclusive   *xsdFacet        `xml:"minInclusive"`
+	MaxInclusive   *xsdFacet        `xml:"maxInclusive"`
+	MinExclusive   *xsdFacet        `xml:"minExclusive"`
+	MaxExclusive   *xsdFacet        `xml:"maxExclusive"`
+	TotalDigits    *xsdFacet        `xml:"totalDigits"`
+	FractionDigits *xsdFacet        `xml:"fractionDigits"`
+	WhiteSpace     *xsdFacet        `xml:"whiteSpace"`
+
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	xsdContentModel
+}
+
+// xsdFacet is a constraining facet of a single value, such as maxLength.
+type xsdFacet struct {
+	Value string `xml:"value,attr"`
 }
 
 type xsdPattern struct {
@@ -155,3 +857,63 @@ type xsdPattern struct {
 type xsdEnumeration struct {
 	Value string `xml:"value,attr"`
 }
+
+// xsdPos is the position of a schema component in its schema document, as
+// reported in errors. The file is stamped by xsdSchema.qualify, while the
+// line and column are recorded as the component is decoded. A position also
+// identifies the component it belongs to, along with the target namespace it
+// is stamped with, as a document without one of its own is loaded once per
+// namespace including it.
+type xsdPos struct {
+	File            string `xml:"-"`
+	Line            int    `xml:"-"`
+	Col             int    `xml:"-"`
+	TargetNamespace string `xml:"-"`
+}
+
+// decodeAt decodes the element at start into v, recording the current
+// position of the decoder in pos.
+func decodeAt(d *xml.Decoder, start xml.StartElement, pos *xsdPos, v interface{}) error {
+	pos.Line, pos.Col = d.InputPos()
+	return d.DecodeElement(v, &start)
+}
+
+func (e *xsdElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdElement
+	return decodeAt(d, start, &e.xsdPos, (*plain)(e))
+}
+
+func (t *xsdComplexType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdComplexType
+	return decodeAt(d, start, &t.xsdPos, (*plain)(t))
+}
+
+func (e *xsdExtension) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdExtension
+	return decodeAt(d, start, &e.xsdPos, (*plain)(e))
+}
+
+func (g *xsdGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdGroup
+	return decodeAt(d, start, &g.xsdPos, (*plain)(g))
+}
+
+func (a *xsdAttribute) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdAttribute
+	return decodeAt(d, start, &a.xsdPos, (*plain)(a))
+}
+
+func (g *xsdAttributeGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdAttributeGroup
+	return decodeAt(d, start, &g.xsdPos, (*plain)(g))
+}
+
+func (t *xsdSimpleType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdSimpleType
+	return decodeAt(d, start, &t.xsdPos, (*plain)(t))
+}
+
+func (r *xsdRestriction) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain xsdRestriction
+	return decodeAt(d, start, &r.xsdPos, (*plain)(r))
+}
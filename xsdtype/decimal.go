@@ -0,0 +1,224 @@
+package xsdtype
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Decimal is an xs:decimal value of arbitrary precision, held as an integer
+// scaled by a power of ten. It keeps the fraction digits of the value as
+// written, trailing zeros included. The zero value is zero.
+type Decimal struct {
+	unscaled *big.Int // nil if zero
+	scale    int      // number of fraction digits
+}
+
+// NewDecimal returns the decimal unscaled × 10^-scale.
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	if scale < 0 {
+		unscaled = new(big.Int).Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// ParseDecimal parses s in the lexical space of xs:decimal.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !decimalRE.MatchString(s) {
+		return Decimal{}, fmt.Errorf("xsdtype: invalid decimal %q", s)
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	unscaled, _ := new(big.Int).SetString(whole+frac, 10)
+	return Decimal{unscaled: unscaled, scale: len(frac)}, nil
+}
+
+// MustParseDecimal is like ParseDecimal, but panics if s is not a decimal.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+var decimalRE = regexp.MustCompile(`^[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)$`)
+
+// Unscaled returns the unscaled value of d, the integer scaled by its scale.
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of fraction digits of d, as written.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Rat returns d as a rational number.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled(), pow10(d.scale))
+}
+
+func (d Decimal) Sign() int {
+	return d.Unscaled().Sign()
+}
+
+// Cmp compares d and e, returning -1, 0 or +1 as d is less than, equal to or
+// greater than e.
+func (d Decimal) Cmp(e Decimal) int {
+	x, y := d.Unscaled(), e.Unscaled()
+	if d.scale < e.scale {
+		x.Mul(x, pow10(e.scale-d.scale))
+	} else {
+		y.Mul(y, pow10(d.scale-e.scale))
+	}
+	return x.Cmp(y)
+}
+
+// TotalDigits returns the least value of the totalDigits facet d satisfies:
+// the number of its digits, less leading and trailing zeros, but no less
+// than its fraction digits.
+func (d Decimal) TotalDigits() int {
+	digits, frac := d.normalized()
+	if len(digits) < frac {
+		return frac
+	}
+	return len(digits)
+}
+
+// FractionDigits returns the number of fraction digits of d, less trailing
+// zeros.
+func (d Decimal) FractionDigits() int {
+	_, frac := d.normalized()
+	return frac
+}
+
+// normalized returns the digits of the absolute unscaled value of d, and its
+// scale, with trailing fraction zeros removed.
+func (d Decimal) normalized() (string, int) {
+	digits := new(big.Int).Abs(d.Unscaled()).String()
+	scale := d.scale
+	for scale > 0 && len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+		scale--
+	}
+	if digits == "0" {
+		scale = 0
+	}
+	return digits, scale
+}
+
+func (d Decimal) String() string {
+	u := d.Unscaled()
+	digits := new(big.Int).Abs(u).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if u.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(b []byte) error {
+	x, err := ParseDecimal(string(b))
+	if err != nil {
+		return err
+	}
+	*d = x
+	return nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Integer is an xs:integer value, or a value of a data type derived from it,
+// of arbitrary size. The zero value is zero. As with Decimal, the value held
+// is never changed, so that copies of an Integer are independent.
+type Integer struct {
+	i *big.Int // nil if zero
+}
+
+// NewInteger returns the integer i.
+func NewInteger(i *big.Int) Integer {
+	return Integer{i: new(big.Int).Set(i)}
+}
+
+// ParseInteger parses s in the lexical space of xs:integer.
+func ParseInteger(s string) (Integer, error) {
+	s = strings.TrimSpace(s)
+	if !integerRE.MatchString(s) {
+		return Integer{}, fmt.Errorf("xsdtype: invalid integer %q", s)
+	}
+	i, _ := new(big.Int).SetString(s, 10)
+	return Integer{i: i}, nil
+}
+
+// MustParseInteger is like ParseInteger, but panics if s is not an integer.
+func MustParseInteger(s string) Integer {
+	i, err := ParseInteger(s)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+var integerRE = regexp.MustCompile(`^[+-]?[0-9]+$`)
+
+// Int returns i as a big.Int.
+func (i Integer) Int() *big.Int {
+	return new(big.Int).Set(i.value())
+}
+
+func (i Integer) Sign() int {
+	return i.value().Sign()
+}
+
+// Cmp compares i and j, returning -1, 0 or +1 as i is less than, equal to or
+// greater than j.
+func (i Integer) Cmp(j Integer) int {
+	return i.value().Cmp(j.value())
+}
+
+// TotalDigits returns the number of digits of i, less leading zeros.
+func (i Integer) TotalDigits() int {
+	return len(new(big.Int).Abs(i.value()).String())
+}
+
+// value returns the value of i, which must not be changed.
+func (i Integer) value() *big.Int {
+	if i.i == nil {
+		return new(big.Int)
+	}
+	return i.i
+}
+
+func (i Integer) String() string {
+	return i.value().String()
+}
+
+func (i Integer) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+func (i *Integer) UnmarshalText(b []byte) error {
+	x, err := ParseInteger(string(b))
+	if err != nil {
+		return err
+	}
+	*i = x
+	return nil
+}
@@ -0,0 +1,94 @@
+package xsdtype
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, tst := range []struct {
+		in             string
+		out            string
+		total, frac    int
+		cmpOneAndAHalf int
+	}{
+		{"1.50", "1.50", 2, 1, 0},
+		{"-0.00123", "-0.00123", 5, 5, -1},
+		{"+.5", "0.5", 1, 1, -1},
+		{"1200", "1200", 4, 0, 1},
+		{"12345678901234567890.123456789", "12345678901234567890.123456789", 29, 9, 1},
+		{"0.000", "0.000", 1, 0, -1},
+		{"7.", "7", 1, 0, 1},
+	} {
+		d, err := ParseDecimal(tst.in)
+		if err != nil {
+			t.Errorf("%s: %v", tst.in, err)
+			continue
+		}
+		if d.String() != tst.out || d.TotalDigits() != tst.total || d.FractionDigits() != tst.frac {
+			t.Errorf("%s: got %s, %d total and %d fraction digits", tst.in, d, d.TotalDigits(), d.FractionDigits())
+		}
+		if c := d.Cmp(MustParseDecimal("1.5")); c != tst.cmpOneAndAHalf {
+			t.Errorf("%s: compared to 1.5 as %d", tst.in, c)
+		}
+	}
+
+	for _, in := range []string{"", ".", "1e3", "1.2.3", "0x10", "NaN"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestInteger(t *testing.T) {
+	i, err := ParseInteger("+123456789012345678901234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.String() != "123456789012345678901234567890" || i.TotalDigits() != 30 {
+		t.Errorf("got %s, with %d digits", i, i.TotalDigits())
+	}
+	if i.Cmp(MustParseInteger("-1")) != 1 {
+		t.Errorf("%s not greater than -1", i)
+	}
+
+	for _, in := range []string{"", "1.0", "0x10", "1_000"} {
+		if _, err := ParseInteger(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+
+	// Copies are independent, and a failed decoding keeps the value.
+	j := i
+	i.Int().SetInt64(1)
+	if err := j.UnmarshalText([]byte("x")); err == nil || j.Cmp(i) != 0 {
+		t.Errorf("got %s and %s, with error %v", i, j, err)
+	}
+	if err := j.UnmarshalText([]byte("-7")); err != nil || j.Cmp(i) != -1 || i.Sign() != 1 {
+		t.Errorf("got %s and %s, with error %v", i, j, err)
+	}
+	var zero Integer
+	if zero.Sign() != 0 || zero.String() != "0" || zero.Cmp(MustParseInteger("0")) != 0 {
+		t.Errorf("zero value %s", zero)
+	}
+}
+
+func TestNumbersXML(t *testing.T) {
+	type doc struct {
+		Amount Decimal `xml:"amount,attr"`
+		Count  Integer `xml:"count"`
+	}
+
+	in := `<doc amount="-10.50"><count>98765432109876543210</count></doc>`
+	var d doc
+	if err := xml.Unmarshal([]byte(in), &d); err != nil {
+		t.Fatal(err)
+	}
+	out, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
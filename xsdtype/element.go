@@ -0,0 +1,139 @@
+package xsdtype
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DecodeElement decodes the element at start into v, as d.DecodeElement
+// does, but hands each child element to child first. If child reports that it
+// has decoded the element itself, v is not given it. It lets the elements of
+// a choice be decoded by their names, which struct tags cannot do.
+//
+// v must not implement xml.Unmarshaler, or it is handed the whole element.
+func DecodeElement(d *xml.Decoder, start xml.StartElement, v interface{}, child func(*xml.Decoder, xml.StartElement) (bool, error)) error {
+	r := &childReader{d: d, start: &start, child: child}
+	return xml.NewTokenDecoder(r).Decode(v)
+}
+
+// childReader reads the tokens of an element from d, starting with the start
+// element, and leaving out the child elements decoded by child.
+type childReader struct {
+	d     *xml.Decoder
+	start *xml.StartElement
+	child func(*xml.Decoder, xml.StartElement) (bool, error)
+	depth int
+}
+
+func (r *childReader) Token() (xml.Token, error) {
+	if r.start != nil {
+		start := *r.start
+		r.start = nil
+		r.depth = 1
+		return start, nil
+	}
+	for r.depth > 0 {
+		tok, err := r.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if r.depth == 1 {
+				done, err := r.child(r.d, t)
+				if err != nil {
+					return nil, err
+				}
+				if done {
+					continue
+				}
+			}
+			r.depth++
+		case xml.EndElement:
+			r.depth--
+		}
+		return xml.CopyToken(tok), nil
+	}
+	return nil, io.EOF
+}
+
+// TypeName resolves value, the QName of a type given by the xsi:type
+// attribute of the element at start, to the one of names it refers to,
+// reporting whether there is one. Its prefix, or the default namespace, is
+// resolved against the namespace declarations of the element. Those of the
+// enclosing elements are not told by the decoder; a value not declared for
+// by the element resolves to the one of names of its local name, if there is
+// just one.
+func TypeName(start xml.StartElement, value string, names []xml.Name) (xml.Name, bool) {
+	prefix, local := "", strings.TrimSpace(value)
+	if i := strings.Index(local, ":"); i >= 0 {
+		prefix, local = local[:i], local[i+1:]
+	}
+
+	decl := xml.Name{Space: "xmlns", Local: prefix}
+	if prefix == "" {
+		decl = xml.Name{Local: "xmlns"}
+	}
+	for _, a := range start.Attr {
+		if a.Name == decl {
+			name := xml.Name{Space: a.Value, Local: local}
+			for _, n := range names {
+				if n == name {
+					return n, true
+				}
+			}
+			return name, false
+		}
+	}
+
+	var name xml.Name
+	found := 0
+	for _, n := range names {
+		if n.Local == local {
+			name = n
+			found++
+		}
+	}
+	return name, found == 1
+}
+
+// PrefixName returns start with its name bound to its namespace by a prefix,
+// rather than by the default namespace, which it undeclares instead. The
+// unqualified elements within are then in no namespace, while encoding/xml
+// would have them in the namespace of start. A prefix start declares for the
+// namespace already is used, or else one is declared.
+func PrefixName(start xml.StartElement) xml.StartElement {
+	if start.Name.Space == "" {
+		return start
+	}
+
+	declared := make(map[string]bool)
+	prefix := ""
+	for _, a := range start.Attr {
+		var p string
+		switch {
+		case a.Name.Space == "xmlns":
+			p = a.Name.Local
+		case a.Name.Space == "" && strings.HasPrefix(a.Name.Local, "xmlns:"):
+			p = strings.TrimPrefix(a.Name.Local, "xmlns:")
+		default:
+			continue
+		}
+		declared[p] = true
+		if a.Value == start.Name.Space && prefix == "" {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		prefix = "ns"
+		for i := 2; declared[prefix]; i++ {
+			prefix = "ns" + strconv.Itoa(i)
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: start.Name.Space})
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}})
+	start.Name = xml.Name{Local: prefix + ":" + start.Name.Local}
+	return start
+}
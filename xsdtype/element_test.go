@@ -0,0 +1,131 @@
+package xsdtype
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodeElement(t *testing.T) {
+	var v struct {
+		ID   string `xml:"id,attr"`
+		Name struct {
+			Pick string `xml:"pick"`
+		} `xml:"name"`
+	}
+	var picked []string
+	child := func(d *xml.Decoder, start xml.StartElement) (bool, error) {
+		switch start.Name.Local {
+		case "name":
+			return false, nil
+		case "pick":
+			var s string
+			err := d.DecodeElement(&s, &start)
+			picked = append(picked, s)
+			return true, err
+		}
+		return true, fmt.Errorf("unexpected element %s", start.Name.Local)
+	}
+
+	d := xml.NewDecoder(strings.NewReader(`<root id="1"><pick>a</pick><name><pick>b</pick></name><pick>c</pick></root><next/>`))
+	start, err := d.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeElement(d, start.(xml.StartElement), &v, child); err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != "1" || v.Name.Pick != "b" || strings.Join(picked, ",") != "a,c" {
+		t.Errorf("got %+v, picked %v", v, picked)
+	}
+	if tok, err := d.Token(); err != nil || tok.(xml.StartElement).Name.Local != "next" {
+		t.Errorf("decoder left at %v, %v", tok, err)
+	}
+
+	d = xml.NewDecoder(strings.NewReader(`<root><other/></root>`))
+	start, _ = d.Token()
+	if err := DecodeElement(d, start.(xml.StartElement), &v, child); err == nil || err.Error() != "unexpected element other" {
+		t.Errorf("expected error for unexpected element, got %v", err)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	names := []xml.Name{
+		{Space: "urn:a", Local: "circle"},
+		{Space: "urn:b", Local: "circle"},
+		{Space: "urn:b", Local: "square"},
+		{Local: "line"},
+	}
+	start := xml.StartElement{Attr: []xml.Attr{
+		{Name: xml.Name{Space: "xmlns", Local: "a"}, Value: "urn:a"},
+		{Name: xml.Name{Local: "xmlns"}, Value: "urn:b"},
+	}}
+	for _, tst := range []struct {
+		value string
+		name  xml.Name
+		ok    bool
+	}{
+		{"a:circle", xml.Name{Space: "urn:a", Local: "circle"}, true},
+		{"circle", xml.Name{Space: "urn:b", Local: "circle"}, true},
+		{"a:square", xml.Name{Space: "urn:a", Local: "square"}, false},
+		{"b:square", xml.Name{Space: "urn:b", Local: "square"}, true},
+		{"b:circle", xml.Name{}, false},
+	} {
+		name, ok := TypeName(start, tst.value, names)
+		if ok != tst.ok || ok && name != tst.name {
+			t.Errorf("TypeName(%q) = %v, %v; want %v, %v", tst.value, name, ok, tst.name, tst.ok)
+		}
+	}
+
+	if name, ok := TypeName(xml.StartElement{}, "line", names); !ok || name != (xml.Name{Local: "line"}) {
+		t.Errorf("TypeName(%q) = %v, %v", "line", name, ok)
+	}
+}
+
+func TestPrefixName(t *testing.T) {
+	type item struct {
+		Name string `xml:"name"`
+	}
+	for _, tst := range []struct {
+		attr []xml.Attr
+		want string
+	}{
+		{nil, `<ns:item xmlns:ns="urn:a" xmlns=""><name>x</name></ns:item>`},
+		{
+			[]xml.Attr{{Name: xml.Name{Local: "xmlns:tns"}, Value: "urn:a"}},
+			`<tns:item xmlns:tns="urn:a" xmlns=""><name>x</name></tns:item>`,
+		},
+		{
+			[]xml.Attr{{Name: xml.Name{Local: "xmlns:ns"}, Value: "urn:b"}},
+			`<ns2:item xmlns:ns="urn:b" xmlns:ns2="urn:a" xmlns=""><name>x</name></ns2:item>`,
+		},
+	} {
+		var b strings.Builder
+		start := xml.StartElement{Name: xml.Name{Space: "urn:a", Local: "item"}, Attr: tst.attr}
+		if err := xml.NewEncoder(&b).EncodeElement(item{"x"}, PrefixName(start)); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tst.want {
+			t.Errorf("got %s, want %s", b.String(), tst.want)
+		}
+
+		var v struct {
+			XMLName xml.Name
+			Name    struct {
+				XMLName xml.Name
+			} `xml:"name"`
+		}
+		if err := xml.Unmarshal([]byte(b.String()), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.XMLName != start.Name || v.Name.XMLName != (xml.Name{Local: "name"}) {
+			t.Errorf("%s decoded as %v and %v", b.String(), v.XMLName, v.Name.XMLName)
+		}
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: "item"}}
+	if got := PrefixName(start); got.Name != start.Name || len(got.Attr) != 0 {
+		t.Errorf("PrefixName of an unqualified name = %v", got)
+	}
+}
@@ -0,0 +1,403 @@
+package xsdtype
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The date and time types hold their values as a time.Time, along with
+// whether the value has a time zone, which is optional for all of them. A
+// value without a time zone is held in UTC. Components not part of a type,
+// such as the year of a GMonthDay, are those of midnight on January 1, 2000,
+// a leap year.
+
+// DateTime is an xs:dateTime value.
+type DateTime struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v DateTime) String() string {
+	return format(v.Time, v.NoZone, "2006-01-02T15:04:05.999999999")
+}
+
+func (v DateTime) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *DateTime) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(dateTimeLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// Date is an xs:date value, held as midnight of the date.
+type Date struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v Date) String() string {
+	return format(v.Time, v.NoZone, "2006-01-02")
+}
+
+func (v Date) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Date) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(dateLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// Time is an xs:time value, held as the time of day of January 1, 2000.
+type Time struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v Time) String() string {
+	return format(v.Time, v.NoZone, "15:04:05.999999999")
+}
+
+func (v Time) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Time) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(timeLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// GYearMonth is an xs:gYearMonth value, held as the first of the month.
+type GYearMonth struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v GYearMonth) String() string {
+	return format(v.Time, v.NoZone, "2006-01")
+}
+
+func (v GYearMonth) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *GYearMonth) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(gYearMonthLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// GYear is an xs:gYear value, held as January 1 of the year.
+type GYear struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v GYear) String() string {
+	return format(v.Time, v.NoZone, "2006")
+}
+
+func (v GYear) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *GYear) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(gYearLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// GMonthDay is an xs:gMonthDay value, recurring every year.
+type GMonthDay struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v GMonthDay) String() string {
+	return format(v.Time, v.NoZone, "--01-02")
+}
+
+func (v GMonthDay) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *GMonthDay) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(gMonthDayLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// GDay is an xs:gDay value, recurring every month.
+type GDay struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v GDay) String() string {
+	return format(v.Time, v.NoZone, "---02")
+}
+
+func (v GDay) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *GDay) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(gDayLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// GMonth is an xs:gMonth value, recurring every year.
+type GMonth struct {
+	Time   time.Time
+	NoZone bool
+}
+
+func (v GMonth) String() string {
+	return format(v.Time, v.NoZone, "--01")
+}
+
+func (v GMonth) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *GMonth) UnmarshalText(b []byte) error {
+	t, noZone, err := parse(gMonthLayout, b)
+	if err != nil {
+		return err
+	}
+	v.Time, v.NoZone = t, noZone
+	return nil
+}
+
+// Duration is an xs:duration value, such as P1Y2M3DT4H5M6.7S, holding its
+// components as written; months and days are not of fixed length, so they
+// are not normalized.
+type Duration struct {
+	Negative    bool
+	Years       int
+	Months      int
+	Days        int
+	Hours       int
+	Minutes     int
+	Seconds     int
+	Nanoseconds int
+}
+
+func (v Duration) String() string {
+	var date, clock string
+	for _, c := range []struct {
+		n          int
+		designator string
+		s          *string
+	}{
+		{v.Years, "Y", &date},
+		{v.Months, "M", &date},
+		{v.Days, "D", &date},
+		{v.Hours, "H", &clock},
+		{v.Minutes, "M", &clock},
+	} {
+		if c.n != 0 {
+			*c.s += strconv.Itoa(c.n) + c.designator
+		}
+	}
+	if v.Seconds != 0 || v.Nanoseconds != 0 || date+clock == "" {
+		clock += strconv.Itoa(v.Seconds)
+		if v.Nanoseconds != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%09d", v.Nanoseconds), "0")
+		}
+		clock += "S"
+	}
+
+	s := "P" + date
+	if clock != "" {
+		s += "T" + clock
+	}
+	if v.Negative {
+		s = "-" + s
+	}
+	return s
+}
+
+func (v Duration) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Duration) UnmarshalText(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	m := durationRE.FindStringSubmatch(s)
+	if m == nil || strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T") {
+		return fmt.Errorf("xsdtype: invalid duration %q", s)
+	}
+
+	var d Duration
+	d.Negative = m[1] == "-"
+	for i, n := range []*int{&d.Years, &d.Months, &d.Days, &d.Hours, &d.Minutes} {
+		if m[i+2] == "" {
+			continue
+		}
+		var err error
+		if *n, err = strconv.Atoi(m[i+2]); err != nil {
+			return fmt.Errorf("xsdtype: invalid duration %q: %v", s, err)
+		}
+	}
+	if m[7] != "" {
+		var err error
+		if d.Seconds, d.Nanoseconds, err = seconds(m[7]); err != nil {
+			return fmt.Errorf("xsdtype: invalid duration %q: %v", s, err)
+		}
+	}
+	*v = d
+	return nil
+}
+
+var durationRE = regexp.MustCompile(`^(-)?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// layout is the lexical space of a date and time type, a regular expression
+// with named groups for the components of the type.
+type layout struct {
+	name string
+	re   *regexp.Regexp
+}
+
+const (
+	yearRE  = `(?P<year>-?(?:[1-9][0-9]{4,}|[0-9]{4}))`
+	monthRE = `(?P<month>[0-9]{2})`
+	dayRE   = `(?P<day>[0-9]{2})`
+	clockRE = `(?P<hour>[0-9]{2}):(?P<minute>[0-9]{2}):(?P<second>[0-9]{2}(?:\.[0-9]+)?)`
+	zoneRE  = `(?P<zone>Z|[+-][0-9]{2}:[0-9]{2})?`
+)
+
+var (
+	dateTimeLayout   = newLayout("dateTime", yearRE+"-"+monthRE+"-"+dayRE+"T"+clockRE)
+	dateLayout       = newLayout("date", yearRE+"-"+monthRE+"-"+dayRE)
+	timeLayout       = newLayout("time", clockRE)
+	gYearMonthLayout = newLayout("gYearMonth", yearRE+"-"+monthRE)
+	gYearLayout      = newLayout("gYear", yearRE)
+	gMonthDayLayout  = newLayout("gMonthDay", "--"+monthRE+"-"+dayRE)
+	gDayLayout       = newLayout("gDay", "---"+dayRE)
+	gMonthLayout     = newLayout("gMonth", "--"+monthRE)
+)
+
+func newLayout(name, re string) layout {
+	return layout{name: name, re: regexp.MustCompile("^" + re + zoneRE + "$")}
+}
+
+// parse parses text in the lexical space of l, returning the time it
+// represents, and whether it has no time zone.
+func parse(l layout, text []byte) (time.Time, bool, error) {
+	s := strings.TrimSpace(string(text))
+	m := l.re.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false, fmt.Errorf("xsdtype: invalid %s %q", l.name, s)
+	}
+
+	year, month, day := 2000, 1, 1
+	var hour, minute, sec, nsec int
+	loc, noZone := time.UTC, true
+	for i, name := range l.re.SubexpNames() {
+		v := m[i]
+		if name == "" || v == "" {
+			continue
+		}
+		var err error
+		switch name {
+		case "year":
+			year, err = strconv.Atoi(v)
+		case "month":
+			month, _ = strconv.Atoi(v)
+		case "day":
+			day, _ = strconv.Atoi(v)
+		case "hour":
+			hour, _ = strconv.Atoi(v)
+		case "minute":
+			minute, _ = strconv.Atoi(v)
+		case "second":
+			sec, nsec, err = seconds(v)
+		case "zone":
+			noZone = false
+			if v != "Z" {
+				h, _ := strconv.Atoi(v[1:3])
+				min, _ := strconv.Atoi(v[4:6])
+				offset := h*60 + min
+				if min > 59 || offset > 14*60 {
+					return time.Time{}, false, fmt.Errorf("xsdtype: invalid %s %q: time zone out of range", l.name, s)
+				}
+				if v[0] == '-' {
+					offset = -offset
+				}
+				loc = time.FixedZone("", offset*60)
+			}
+		}
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("xsdtype: invalid %s %q: %v", l.name, s, err)
+		}
+	}
+
+	// The end of a day, 24:00:00, is the start of the next one.
+	endOfDay := hour == 24 && minute == 0 && sec == 0 && nsec == 0
+	daysIn := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if month < 1 || month > 12 || day < 1 || day > daysIn || (hour > 23 && !endOfDay) || minute > 59 || sec > 59 {
+		return time.Time{}, false, fmt.Errorf("xsdtype: invalid %s %q: out of range", l.name, s)
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, sec, nsec, loc), noZone, nil
+}
+
+// seconds parses seconds with an optional fraction, down to nanoseconds.
+func seconds(s string) (int, int, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(frac) > 9 {
+		frac = frac[:9]
+	}
+	nsec := 0
+	if frac != "" {
+		nsec, _ = strconv.Atoi(frac + strings.Repeat("0", 9-len(frac)))
+	}
+	return sec, nsec, nil
+}
+
+// format formats t by the layout of a date and time type, with its time
+// zone unless it has none.
+func format(t time.Time, noZone bool, layout string) string {
+	s := t.Format(layout)
+	if noZone {
+		return s
+	}
+	_, offset := t.Zone()
+	if offset == 0 {
+		return s + "Z"
+	}
+	return s + t.Format("-07:00")
+}
@@ -0,0 +1,150 @@
+package xsdtype
+
+import (
+	"encoding"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+type text interface {
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
+
+func TestTemporal(t *testing.T) {
+	for _, tst := range []struct {
+		v    text
+		in   string
+		out  string // canonical form, if other than in
+		want time.Time
+	}{
+		{v: &DateTime{}, in: "2002-10-10T12:00:00-05:00", want: time.Date(2002, 10, 10, 17, 0, 0, 0, time.UTC)},
+		{v: &DateTime{}, in: "2002-10-10T12:00:00", want: time.Date(2002, 10, 10, 12, 0, 0, 0, time.UTC)},
+		{v: &DateTime{}, in: "2002-10-10T12:00:00.250Z", out: "2002-10-10T12:00:00.25Z", want: time.Date(2002, 10, 10, 12, 0, 0, 250e6, time.UTC)},
+		{v: &DateTime{}, in: "1999-12-31T24:00:00", out: "2000-01-01T00:00:00", want: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{v: &DateTime{}, in: "-0044-03-15T12:00:00", want: time.Date(-44, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{v: &Date{}, in: "2004-02-29", want: time.Date(2004, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{v: &Date{}, in: "2004-02-29+14:00", want: time.Date(2004, 2, 28, 10, 0, 0, 0, time.UTC)},
+		{v: &Time{}, in: "13:20:00.000001", want: time.Date(2000, 1, 1, 13, 20, 0, 1000, time.UTC)},
+		{v: &GYearMonth{}, in: "1999-05Z", want: time.Date(1999, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{v: &GYear{}, in: "12021", want: time.Date(12021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{v: &GMonthDay{}, in: "--02-29", want: time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{v: &GDay{}, in: "---31", want: time.Date(2000, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{v: &GMonth{}, in: "--12", want: time.Date(2000, 12, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := tst.v.UnmarshalText([]byte(tst.in)); err != nil {
+			t.Errorf("%T %s: %v", tst.v, tst.in, err)
+			continue
+		}
+		if got := timeOf(tst.v); !got.Equal(tst.want) {
+			t.Errorf("%T %s: got %v, want %v", tst.v, tst.in, got, tst.want)
+		}
+		out, _ := tst.v.MarshalText()
+		if want := tst.out; want == "" && string(out) != tst.in || want != "" && string(out) != want {
+			t.Errorf("%T %s: marshalled as %s", tst.v, tst.in, out)
+		}
+	}
+
+	for _, tst := range []struct {
+		v  text
+		in string
+	}{
+		{&DateTime{}, "2002-10-10"},
+		{&DateTime{}, "2002-10-10T25:00:00"},
+		{&DateTime{}, "2002-10-10T12:00:00+15:00"},
+		{&Date{}, "2003-02-29"},
+		{&Date{}, "02-10-10"},
+		{&Time{}, "12:60:00"},
+		{&GMonth{}, "--13"},
+		{&Duration{}, "P"},
+		{&Duration{}, "P1YT"},
+		{&Duration{}, "PT1.S"},
+		{&Duration{}, "1Y"},
+	} {
+		if err := tst.v.UnmarshalText([]byte(tst.in)); err == nil {
+			t.Errorf("%T %s: expected error", tst.v, tst.in)
+		}
+	}
+
+	// A value is kept when decoding another one fails.
+	var d Date
+	if err := d.UnmarshalText([]byte("2003-02-28Z")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UnmarshalText([]byte("2003-02-29")); err == nil || d.String() != "2003-02-28Z" {
+		t.Errorf("got %s, with error %v", d, err)
+	}
+}
+
+func timeOf(v text) time.Time {
+	switch v := v.(type) {
+	case *DateTime:
+		return v.Time
+	case *Date:
+		return v.Time
+	case *Time:
+		return v.Time
+	case *GYearMonth:
+		return v.Time
+	case *GYear:
+		return v.Time
+	case *GMonthDay:
+		return v.Time
+	case *GDay:
+		return v.Time
+	case *GMonth:
+		return v.Time
+	}
+	return time.Time{}
+}
+
+func TestDuration(t *testing.T) {
+	for _, tst := range []struct {
+		in   string
+		out  string
+		want Duration
+	}{
+		{in: "P1Y2M3DT4H5M6.7S", want: Duration{Years: 1, Months: 2, Days: 3, Hours: 4, Minutes: 5, Seconds: 6, Nanoseconds: 7e8}},
+		{in: "-P120D", want: Duration{Negative: true, Days: 120}},
+		{in: "PT36H", want: Duration{Hours: 36}},
+		{in: "P0Y", out: "PT0S"},
+	} {
+		var d Duration
+		if err := d.UnmarshalText([]byte(tst.in)); err != nil {
+			t.Errorf("%s: %v", tst.in, err)
+			continue
+		}
+		if d != tst.want {
+			t.Errorf("%s: got %+v, want %+v", tst.in, d, tst.want)
+		}
+		out := tst.out
+		if out == "" {
+			out = tst.in
+		}
+		if d.String() != out {
+			t.Errorf("%s: formatted as %s, want %s", tst.in, d, out)
+		}
+	}
+}
+
+func TestTemporalXML(t *testing.T) {
+	type doc struct {
+		At   DateTime `xml:"at,attr"`
+		Day  Date     `xml:"day"`
+		Wait Duration `xml:"wait"`
+	}
+
+	in := `<doc at="2002-10-10T12:00:00"><day>2002-10-10Z</day><wait>PT1M</wait></doc>`
+	var d doc
+	if err := xml.Unmarshal([]byte(in), &d); err != nil {
+		t.Fatal(err)
+	}
+	out, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
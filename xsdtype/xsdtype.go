@@ -0,0 +1,47 @@
+// Package xsdtype provides Go types for the XSD built-in data types that
+// have no Go counterpart, for use by the code generated by goxsd. Each type
+// marshals to and from the lexical space of its data type, in both elements
+// and attributes.
+package xsdtype
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Base64Binary is an xs:base64Binary value, holding the decoded octets.
+type Base64Binary []byte
+
+func (b Base64Binary) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(b)), nil
+}
+
+// UnmarshalText decodes the base64 encoded text, ignoring any whitespace.
+func (b *Base64Binary) UnmarshalText(text []byte) error {
+	s := strings.Join(strings.Fields(string(text)), "")
+	v, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// HexBinary is an xs:hexBinary value, holding the decoded octets.
+type HexBinary []byte
+
+// MarshalText encodes the octets in upper case hexadecimal, the canonical
+// representation of xs:hexBinary.
+func (b HexBinary) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+func (b *HexBinary) UnmarshalText(text []byte) error {
+	v, err := hex.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
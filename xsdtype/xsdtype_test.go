@@ -0,0 +1,38 @@
+package xsdtype
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	type doc struct {
+		Base64 Base64Binary `xml:"b64"`
+		Hex    HexBinary    `xml:"hex,attr"`
+	}
+
+	in := `<doc hex="0fA1"><b64>aGVs
+		bG8=</b64></doc>`
+	var d doc
+	if err := xml.Unmarshal([]byte(in), &d); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.Base64, []byte("hello")) || !bytes.Equal(d.Hex, []byte{0x0f, 0xa1}) {
+		t.Errorf("Unexpected decoded values: %q, %x", d.Base64, d.Hex)
+	}
+
+	out, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<doc hex="0FA1"><b64>aGVsbG8=</b64></doc>`; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+
+	for _, in := range []string{`<doc><b64>a$==</b64></doc>`, `<doc hex="0g"></doc>`} {
+		if err := xml.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("Expected error decoding %s", in)
+		}
+	}
+}